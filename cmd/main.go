@@ -36,5 +36,7 @@ func main() {
 		log.Printf("param2=%s", ctx.Get("param2"))
 		ctx.String(200, "Get Param %s", ctx.Post("param2"))
 	})
-	engine.Run(":8089")
+	if err := engine.RunWithSignals(":8089"); err != nil {
+		log.Fatal(err)
+	}
 }
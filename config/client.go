@@ -18,6 +18,7 @@ import (
 
 	"linac"
 	"linac/config/env"
+	"linac/net/http/linac/sign"
 )
 
 var (
@@ -71,6 +72,8 @@ type config struct {
 	Addr      string // 配置中心主机
 	Path      string // 配置文件路径
 	Token     string // 获取配置请求需要的token
+	AppKey    string // 请求签名的 app key，为空时不签名
+	AppSecret string // 请求签名的密钥
 	Customize string // 配置自定义字段
 
 	// 应用环境
@@ -108,6 +111,8 @@ func init() {
 	conf.Path = os.Getenv("CONF_PATH")
 	conf.DeployEnv = os.Getenv("CONF_ENV")
 	conf.Token = os.Getenv("CONF_TOKEN")
+	conf.AppKey = os.Getenv("CONF_APP_KEY")
+	conf.AppSecret = os.Getenv("CONF_APP_SECRET")
 	conf.Region = os.Getenv("REGION")
 	conf.Zone = os.Getenv("ZONE")
 	conf.AppID = os.Getenv("APP_ID")
@@ -118,6 +123,8 @@ func init() {
 	flag.StringVar(&conf.Path, "conf_path", conf.Path, `config file path.`)
 	flag.StringVar(&conf.DeployEnv, "conf_env", conf.DeployEnv, `config Env.`)
 	flag.StringVar(&conf.Token, "conf_token", conf.Token, `config Token.`)
+	flag.StringVar(&conf.AppKey, "conf_app_key", conf.AppKey, `config request sign app key.`)
+	flag.StringVar(&conf.AppSecret, "conf_app_secret", conf.AppSecret, `config request sign secret.`)
 
 	// env set
 	conf.Region = env.Region
@@ -136,6 +143,9 @@ func New() (cli *Client, err error) {
 		httpCli: &http.Client{Timeout: _httpTimeout},
 		event:   make(chan string, 10),
 	}
+	if conf.AppKey != "" {
+		cli.httpCli.Transport = &sign.Transport{Signer: sign.New(conf.AppKey, []byte(conf.AppSecret))}
+	}
 
 	if conf.AppName != "" && conf.Hostname != "" && conf.Path != "" && conf.Addr != "" && conf.Token != "" &&
 		conf.Version != "" && conf.AppID != "" && conf.Since != "" && conf.DeployEnv != "" && conf.Zone != "" && conf.Region != "" {
@@ -2,6 +2,14 @@ package error
 
 //公共错误码
 var (
-	OK        = add(0)
-	ServerErr = add(500)
+	OK                    = add(0)
+	RequestErr            = add(400)
+	Unauthorized          = add(401)
+	Forbidden             = add(403)
+	Conflict              = add(409)
+	RequestEntityTooLarge = add(413)
+	UnprocessableEntity   = add(422)
+	TooManyRequests       = add(429)
+	ServerErr             = add(500)
+	ServiceUnavailable    = add(503)
 )
@@ -3,7 +3,9 @@ module linac
 go 1.15
 
 require (
+	github.com/golang/protobuf v1.4.2
 	github.com/pkg/errors v0.9.1
+	github.com/prometheus/client_model v0.2.0
 	github.com/prometheus/common v0.15.0
 	github.com/stretchr/testify v1.4.0
 )
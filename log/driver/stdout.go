@@ -11,7 +11,7 @@ var _stdOut = map[int]*log.Logger{
 	_infoIdx:  log.New(os.Stdout, "[INFO]:", log.LstdFlags),
 	_warnIdx:  log.New(os.Stdout, "[WARN]:", log.LstdFlags),
 	_errorIdx: log.New(os.Stderr, "[ERROR]:", log.LstdFlags),
-	_totalIdx: log.New(os.Stderr, "[FATAL]:", log.LstdFlags),
+	_fatalIdx: log.New(os.Stderr, "[FATAL]:", log.LstdFlags),
 }
 
 // StdOut log stdout driver
@@ -24,6 +24,6 @@ func (so *StdOut) Write(bs []byte, level int) (int, error) {
 	if level >= _totalIdx {
 		return 0, fmt.Errorf("unsport log level %d", level)
 	}
-	so.ios[level].Print(bs)
+	so.ios[level].Print(string(bs))
 	return len(bs), nil
 }
@@ -0,0 +1,29 @@
+package driver
+
+import (
+	"bytes"
+	"log"
+	"testing"
+)
+
+func TestStdOut(t *testing.T) {
+	so := NewStdOut()
+	for idx := 0; idx < _totalIdx; idx++ {
+		if so.ios[idx] == nil {
+			t.Fatalf("stdout logger %d is nil \n", idx)
+		}
+	}
+	var buf bytes.Buffer
+	for idx := 0; idx < _totalIdx; idx++ {
+		so.ios[idx] = log.New(&buf, "", 0)
+	}
+	if _, err := so.Write([]byte("fatal"), _fatalIdx); err != nil {
+		t.Fatalf("write fatal error: %v \n", err)
+	}
+	if buf.String() != "fatal\n" {
+		t.Errorf("stdout write error, expected %q, get %q \n", "fatal\n", buf.String())
+	}
+	if _, err := so.Write([]byte("unknown"), _totalIdx); err == nil {
+		t.Errorf("stdout write should fail for unknown level \n")
+	}
+}
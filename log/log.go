@@ -1,6 +1,7 @@
 package log
 
 import (
+	"context"
 	"fmt"
 	"linac"
 	"linac/log/driver"
@@ -19,6 +20,19 @@ type kv struct {
 	value interface{}
 }
 
+type ctxKey struct{}
+
+// D 日志字段
+type D struct {
+	Key   string
+	Value interface{}
+}
+
+// KV 返回一个日志字段
+func KV(key string, value interface{}) D {
+	return D{Key: key, Value: value}
+}
+
 // 日志等级
 const (
 	LevelDebug = iota
@@ -46,12 +60,16 @@ var (
 )
 
 var (
-	_r = &render{}
-	_d Driver
+	_r        = &render{}
+	_d Driver = _defaultDriver
 	_v int
 	_c = &Config{}
 )
 
+func init() {
+	_r.parse(_defaultFormat)
+}
+
 // Config Config
 type Config struct {
 	Driver     string
@@ -81,7 +99,7 @@ func log(level int, kvs ...*kv) {
 	if level < fl {
 		return
 	}
-	kvs = append(kvs, kV(_fullSource, file), kV(_finSource, fmt.Sprintf("%s:%d", path.Base(file), line)), kV(_function, funcname))
+	kvs = append(kvs, kV(_level, _mapLevel[level]), kV(_fullSource, file), kV(_finSource, fmt.Sprintf("%s:%d", path.Base(file), line)), kV(_function, funcname))
 	m := make(map[string]interface{})
 	for _, kv := range kvs {
 		m[kv.key] = kv.value
@@ -119,6 +137,71 @@ func Fatal(sfmt string, v ...interface{}) {
 	log(LevelFatal, kV(_message, fmt.Sprintf(sfmt, v...)))
 }
 
+// NewContext 返回携带日志字段的 context
+// 使用该 context 输出的日志 (Infoc、Infov 等) 都会包含这些字段，如请求 ID
+func NewContext(ctx context.Context, args ...D) context.Context {
+	fields := fromContext(ctx)
+	merged := make([]D, 0, len(fields)+len(args))
+	merged = append(merged, fields...)
+	return context.WithValue(ctx, ctxKey{}, append(merged, args...))
+}
+
+func fromContext(ctx context.Context) []D {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(ctxKey{}).([]D)
+	return fields
+}
+
+// Infoc 输出 INFO 日志，并附带 ctx 中的日志字段
+func Infoc(ctx context.Context, sfmt string, v ...interface{}) {
+	log(LevelInfo, kV(_message, contextMessage(ctx, sfmt, v)))
+}
+
+// Warnc 输出 WARN 日志，并附带 ctx 中的日志字段
+func Warnc(ctx context.Context, sfmt string, v ...interface{}) {
+	log(LevelWarn, kV(_message, contextMessage(ctx, sfmt, v)))
+}
+
+// Errorc 输出 ERROR 日志，并附带 ctx 中的日志字段
+func Errorc(ctx context.Context, sfmt string, v ...interface{}) {
+	log(LevelError, kV(_message, contextMessage(ctx, sfmt, v)))
+}
+
+// Infov 输出带字段的 INFO 日志，ctx 中的字段在前，字段以 key=value 的形式按顺序输出
+func Infov(ctx context.Context, args ...D) {
+	log(LevelInfo, kV(_message, contextFields(ctx, args)))
+}
+
+// Warnv 输出带字段的 WARN 日志
+func Warnv(ctx context.Context, args ...D) {
+	log(LevelWarn, kV(_message, contextFields(ctx, args)))
+}
+
+// Errorv 输出带字段的 ERROR 日志
+func Errorv(ctx context.Context, args ...D) {
+	log(LevelError, kV(_message, contextFields(ctx, args)))
+}
+
+func contextMessage(ctx context.Context, sfmt string, v []interface{}) string {
+	msg := fmt.Sprintf(sfmt, v...)
+	if fields := fromContext(ctx); len(fields) > 0 {
+		msg += " " + formatFields(fields)
+	}
+	return msg
+}
+
+func contextFields(ctx context.Context, args []D) []D {
+	fields := fromContext(ctx)
+	if len(fields) == 0 {
+		return args
+	}
+	merged := make([]D, 0, len(fields)+len(args))
+	merged = append(merged, fields...)
+	return append(merged, args...)
+}
+
 func kV(key string, value interface{}) *kv {
 	return &kv{key: key, value: value}
 }
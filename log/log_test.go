@@ -0,0 +1,85 @@
+package log
+
+import (
+	"context"
+	"linac/log/driver"
+	"strings"
+	"testing"
+)
+
+type testDriver struct {
+	lines  []string
+	levels []int
+}
+
+func (d *testDriver) Write(bs []byte, level int) (int, error) {
+	d.lines = append(d.lines, string(bs))
+	d.levels = append(d.levels, level)
+	return len(bs), nil
+}
+
+func withTestDriver(t *testing.T) *testDriver {
+	d := &testDriver{}
+	old := _d
+	_d = d
+	t.Cleanup(func() { _d = old })
+	return d
+}
+
+func TestDefaultDriver(t *testing.T) {
+	if _, ok := _d.(*driver.StdOut); !ok {
+		t.Errorf("default driver error, expected *driver.StdOut, get %T \n", _d)
+	}
+	if len(_r.sli) == 0 {
+		t.Errorf("default format is not parsed \n")
+	}
+}
+
+func TestLogLevelKey(t *testing.T) {
+	d := withTestDriver(t)
+	Info("hello %s", "linac")
+	Warn("warn")
+	Error("error")
+	if len(d.lines) != 3 {
+		t.Fatalf("log lines error, expected 3, get %d \n", len(d.lines))
+	}
+	for i, l := range []string{"[INFO]hello linac", "[WARN]warn", "[ERROR]error"} {
+		if !strings.HasSuffix(d.lines[i], l) {
+			t.Errorf("log line error, expected suffix %s, get %s \n", l, d.lines[i])
+		}
+	}
+	if levels := []int{LevelInfo, LevelWarn, LevelError}; d.levels[0] != levels[0] || d.levels[1] != levels[1] || d.levels[2] != levels[2] {
+		t.Errorf("log levels error, expected %v, get %v \n", levels, d.levels)
+	}
+}
+
+func TestLogFields(t *testing.T) {
+	d := withTestDriver(t)
+	ctx := NewContext(context.Background(), KV("request_id", "abc"))
+	Infov(ctx, KV("status", 200), KV("path", "/a b"))
+	if l := `[INFO]request_id=abc status=200 path="/a b"`; len(d.lines) != 1 || !strings.HasSuffix(d.lines[0], l) {
+		t.Errorf("log fields error, expected suffix %s, get %v \n", l, d.lines)
+	}
+}
+
+func TestFormatFields(t *testing.T) {
+	cases := []struct {
+		fields []D
+		expect string
+	}{
+		{nil, ""},
+		{[]D{KV("a", 1), KV("b", true)}, "a=1 b=true"},
+		{[]D{KV("empty", "")}, `empty=""`},
+		{[]D{KV("space", "a b"), KV("eq", "a=b"), KV("quote", `a"b`), KV("tab", "a\tb"), KV("nl", "a\nb")},
+			`space="a b" eq="a=b" quote="a\"b" tab="a\tb" nl="a\nb"`},
+		{[]D{KV("cr", "a\rb"), KV("esc", "a\x1bb"), KV("nul", "a\x00b"), KV("invalid", "a\xffb")},
+			`cr="a\rb" esc="a\x1bb" nul="a\x00b" invalid="a\xffb"`},
+		{[]D{KV("unicode", "中文")}, "unicode=中文"},
+		{[]D{KV("err", context.Canceled)}, `err="context canceled"`},
+	}
+	for _, c := range cases {
+		if s := formatFields(c.fields); s != c.expect {
+			t.Errorf("format fields error, expected %s, get %s \n", c.expect, s)
+		}
+	}
+}
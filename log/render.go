@@ -3,7 +3,11 @@ package log
 import (
 	"bytes"
 	"fmt"
+	"sort"
+	"strconv"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 const (
@@ -109,11 +113,56 @@ func defaultFormatFuncFactory(s string) func(map[string]interface{}) string {
 func keyFormatFuncFactory(key string) func(map[string]interface{}) string {
 	return func(d map[string]interface{}) string {
 		if v, ok := d[key]; ok {
-			if s, ok := v.(string); ok {
-				return s
+			switch v := v.(type) {
+			case string:
+				return v
+			case []D:
+				return formatFields(v)
+			case map[string]interface{}:
+				fields := make([]D, 0, len(v))
+				for k, value := range v {
+					fields = append(fields, KV(k, value))
+				}
+				sort.Slice(fields, func(i, j int) bool { return fields[i].Key < fields[j].Key })
+				return formatFields(fields)
 			}
 			return fmt.Sprint(v)
 		}
 		return ""
 	}
 }
+
+// formatFields 将字段格式化为 key=value，包含空白、控制字符等的值使用引号
+func formatFields(fields []D) string {
+	var buf bytes.Buffer
+	for i, d := range fields {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		v, ok := d.Value.(string)
+		if !ok {
+			v = fmt.Sprint(d.Value)
+		}
+		if needQuote(v) {
+			v = strconv.Quote(v)
+		}
+		buf.WriteString(d.Key)
+		buf.WriteByte('=')
+		buf.WriteString(v)
+	}
+	return buf.String()
+}
+
+// needQuote 值为空或包含空白、'='、'"'、控制字符以及非法的 UTF-8 时需要引号，
+// 避免伪造日志行或字段
+func needQuote(v string) bool {
+	if v == "" {
+		return true
+	}
+	for _, r := range v {
+		if r == utf8.RuneError || r == '=' || r == '"' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
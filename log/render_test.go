@@ -2,6 +2,7 @@ package log
 
 import (
 	"bytes"
+	"context"
 	"testing"
 	"time"
 )
@@ -186,3 +187,18 @@ func TestFinSource(t *testing.T) {
 		t.Errorf("fin source error, expected %s, get %s \n", l, fl)
 	}
 }
+
+func TestContextFields(t *testing.T) {
+	ctx := NewContext(context.Background(), KV("request_id", "abc"))
+	ctx = NewContext(ctx, KV("trace_id", "t 1"))
+	msg := contextMessage(ctx, "hello %s", []interface{}{"linac"})
+	if l := `hello linac request_id=abc trace_id="t 1"`; msg != l {
+		t.Errorf("context message error, expected %s, get %s \n", l, msg)
+	}
+	r := &render{}
+	r.parse("%M")
+	fl := r.foramt(map[string]interface{}{_message: contextFields(ctx, []D{KV("status", 200)})})
+	if l := `request_id=abc trace_id="t 1" status=200`; fl != l {
+		t.Errorf("context fields error, expected %s, get %s \n", l, fl)
+	}
+}
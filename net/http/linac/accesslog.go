@@ -0,0 +1,123 @@
+package linac
+
+import (
+	"context"
+	"fmt"
+	xerror "linac/error"
+	"linac/log"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+var (
+	_defaultAccessLogConfig = &AccessLogConfig{
+		SampleRate:    1,
+		SlowThreshold: time.Millisecond * time.Duration(500),
+	}
+
+	_defaultAccessLogFields = []string{
+		"route", "method", "path", "status", "bytes", "latency",
+		"ip", "user_agent", "request_id", "code",
+	}
+
+	_accessLogFields = map[string]func(*accessEntry) interface{}{
+		"route":      func(e *accessEntry) interface{} { return e.ctx.RouteName() },
+		"method":     func(e *accessEntry) interface{} { return e.ctx.Request.Method },
+		"path":       func(e *accessEntry) interface{} { return e.ctx.Request.URL.Path },
+		"status":     func(e *accessEntry) interface{} { return e.status },
+		"bytes":      func(e *accessEntry) interface{} { return e.w.size },
+		"latency":    func(e *accessEntry) interface{} { return e.latency },
+		"ip":         func(e *accessEntry) interface{} { return e.ctx.ClientIP() },
+		"user_agent": func(e *accessEntry) interface{} { return e.ctx.Request.UserAgent() },
+		"request_id": func(e *accessEntry) interface{} { return e.ctx.RequestID() },
+		"code":       func(e *accessEntry) interface{} { return e.code },
+	}
+
+	// _writeAccessLog 输出访问日志，测试时替换
+	_writeAccessLog = writeAccessLog
+)
+
+// AccessLogConfig 访问日志配置
+type AccessLogConfig struct {
+	// Fields 输出的字段及顺序，为空时输出全部字段。可选字段：
+	// route, method, path, status, bytes, latency, ip, user_agent, request_id, code
+	Fields []string
+	// SampleRate 2xx 响应的采样比例，取值 (0, 1]，<= 0 时全部输出。
+	// 非 2xx 响应和慢请求总是输出
+	SampleRate float64
+	// SlowThreshold 耗时超过该值的请求以 WARN 等级输出，<= 0 时不检查
+	SlowThreshold time.Duration
+}
+
+type accessEntry struct {
+	ctx     *Context
+	w       *statusWriter
+	latency time.Duration
+	status  int
+	code    int
+}
+
+// AccessLog 使用默认配置的访问日志中间件
+func AccessLog() Handler {
+	return AccessLogWithConfig(_defaultAccessLogConfig)
+}
+
+// AccessLogWithConfig 访问日志中间件
+// 每个请求通过 linac/log 输出一条日志
+func AccessLogWithConfig(conf *AccessLogConfig) Handler {
+	if conf == nil {
+		conf = _defaultAccessLogConfig
+	}
+	fields := conf.Fields
+	if len(fields) == 0 {
+		fields = _defaultAccessLogFields
+	}
+	for _, name := range fields {
+		if _, ok := _accessLogFields[name]; !ok {
+			panic(fmt.Errorf("access log: unknown field '%s'", name))
+		}
+	}
+	return func(ctx *Context) {
+		start := time.Now()
+		w := newStatusWriter(ctx.Writer)
+		ctx.Writer = w
+		completed := false
+		defer func() {
+			ctx.Writer = w.ResponseWriter
+			entry := &accessEntry{
+				ctx:     ctx,
+				w:       w,
+				latency: time.Since(start),
+				status:  w.status,
+				code:    xerror.Cause(ctx.Error).Code(),
+			}
+			if !completed {
+				// handler panic 时由 Recovery 返回 500
+				entry.status, entry.code = http.StatusInternalServerError, xerror.ServerErr.Code()
+			}
+			slow := conf.SlowThreshold > 0 && entry.latency >= conf.SlowThreshold
+			success := entry.status >= 200 && entry.status < 300
+			if success && !slow && conf.SampleRate > 0 && conf.SampleRate < 1 && rand.Float64() >= conf.SampleRate {
+				return
+			}
+			args := make([]log.D, 0, len(fields))
+			for _, name := range fields {
+				args = append(args, log.KV(name, _accessLogFields[name](entry)))
+			}
+			_writeAccessLog(slow, args...)
+		}()
+		ctx.Next()
+		completed = true
+	}
+}
+
+// writeAccessLog 慢请求以 WARN 等级输出，其他请求以 INFO 等级输出
+// 字段中已经包含了请求 ID，不再附带 ctx 中的日志字段
+func writeAccessLog(slow bool, args ...log.D) {
+	if slow {
+		log.Warnv(context.Background(), args...)
+		return
+	}
+	log.Infov(context.Background(), args...)
+}
@@ -0,0 +1,113 @@
+package linac
+
+import (
+	"linac/log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type accessLine struct {
+	slow   bool
+	fields map[string]interface{}
+}
+
+func TestAccessLog(t *testing.T) {
+	var lines []accessLine
+	conf := &AccessLogConfig{
+		Fields:        []string{"route", "method", "path", "status", "bytes", "code", "request_id"},
+		SlowThreshold: 50 * time.Millisecond,
+	}
+	_writeAccessLog = func(slow bool, args ...log.D) {
+		fields := make(map[string]interface{}, len(args))
+		for _, d := range args {
+			fields[d.Key] = d.Value
+		}
+		lines = append(lines, accessLine{slow: slow, fields: fields})
+	}
+	defer func() {
+		_writeAccessLog = writeAccessLog
+	}()
+	engine := NewEngine()
+	engine.Use(AccessLogWithConfig(conf))
+	engine.GET("/access-ok", "access.ok", func(ctx *Context) {
+		ctx.String(200, "hello")
+	})
+	engine.GET("/access-slow", "access.slow", func(ctx *Context) {
+		time.Sleep(60 * time.Millisecond)
+		ctx.String(200, "slow")
+	})
+	engine.GET("/access-panic", "access.panic", func(ctx *Context) {
+		panic("boom")
+	})
+	do := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set(_headerRequestID, "req-1")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("line", func(t *testing.T) {
+		lines = nil
+		do("/access-ok")
+		assert.Len(t, lines, 1)
+		assert.False(t, lines[0].slow)
+		assert.Equal(t, map[string]interface{}{
+			"route":      "access.ok",
+			"method":     "GET",
+			"path":       "/access-ok",
+			"status":     200,
+			"bytes":      int64(5),
+			"code":       0,
+			"request_id": "req-1",
+		}, lines[0].fields)
+	})
+
+	t.Run("slow", func(t *testing.T) {
+		lines = nil
+		do("/access-slow")
+		assert.Len(t, lines, 1)
+		assert.True(t, lines[0].slow)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		lines = nil
+		w := do("/access-panic")
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Len(t, lines, 1)
+		assert.Equal(t, http.StatusInternalServerError, lines[0].fields["status"])
+		assert.Equal(t, 500, lines[0].fields["code"])
+		// Recovery 写入的是原始的 writer
+		assert.Contains(t, w.Body.String(), `"code":500`)
+	})
+
+	t.Run("sample", func(t *testing.T) {
+		lines = nil
+		sampled := *conf
+		sampled.SampleRate = 0.000001
+		engine := NewEngine()
+		engine.Use(AccessLogWithConfig(&sampled))
+		engine.GET("/access-ok", "access.ok", func(ctx *Context) {
+			ctx.String(200, "hello")
+		})
+		engine.GET("/access-missing", "access.missing", func(ctx *Context) {
+			ctx.String(404, "missing")
+		})
+		for i := 0; i < 10; i++ {
+			engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/access-ok", nil))
+		}
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/access-missing", nil))
+		assert.Len(t, lines, 1)
+		assert.Equal(t, 404, lines[0].fields["status"])
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		assert.Panics(t, func() {
+			AccessLogWithConfig(&AccessLogConfig{Fields: []string{"unknown"}})
+		})
+	})
+}
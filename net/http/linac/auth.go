@@ -0,0 +1,196 @@
+package linac
+
+import (
+	"context"
+	"crypto/subtle"
+	xerror "linac/error"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	_headerAuthorization   = "Authorization"
+	_headerWWWAuthenticate = "WWW-Authenticate"
+	_headerAPIKey          = "X-API-Key"
+)
+
+type claimsKey struct{}
+
+// Claims 认证通过后的身份信息，如 JWT 的 payload
+type Claims map[string]interface{}
+
+// Subject 返回 sub
+func (c Claims) Subject() string {
+	s, _ := c["sub"].(string)
+	return s
+}
+
+// Issuer 返回 iss
+func (c Claims) Issuer() string {
+	s, _ := c["iss"].(string)
+	return s
+}
+
+// Audience 返回 aud，aud 可以是字符串或字符串数组
+func (c Claims) Audience() []string {
+	switch aud := c["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []string:
+		return aud
+	case []interface{}:
+		auds := make([]string, 0, len(aud))
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				auds = append(auds, s)
+			}
+		}
+		return auds
+	}
+	return nil
+}
+
+// ExpiresAt 返回 exp，不存在时返回零值
+func (c Claims) ExpiresAt() time.Time {
+	return c.time("exp")
+}
+
+// NotBefore 返回 nbf，不存在时返回零值
+func (c Claims) NotBefore() time.Time {
+	return c.time("nbf")
+}
+
+func (c Claims) time(name string) time.Time {
+	switch v := c[name].(type) {
+	case float64:
+		return time.Unix(int64(v), 0)
+	case int64:
+		return time.Unix(v, 0)
+	case int:
+		return time.Unix(int64(v), 0)
+	case string:
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return time.Unix(i, 0)
+		}
+	}
+	return time.Time{}
+}
+
+// Claims 返回认证中间件写入的身份信息，未认证时返回 nil
+func (ctx *Context) Claims() Claims {
+	claims, _ := ClaimsFromContext(ctx)
+	return claims
+}
+
+// ClaimsFromContext 从 context 中获取身份信息
+func ClaimsFromContext(c context.Context) (claims Claims, ok bool) {
+	if c == nil {
+		return nil, false
+	}
+	claims, ok = c.Value(claimsKey{}).(Claims)
+	return
+}
+
+// setClaims 保存认证通过的身份信息，并检查权限，无权限时返回 403
+func (ctx *Context) setClaims(claims Claims, authorize func(*Context, Claims) bool) bool {
+	ctx.Context = context.WithValue(ctx.Context, claimsKey{}, claims)
+	if authorize != nil && !authorize(ctx, claims) {
+		ctx.AbortWithError(http.StatusForbidden, xerror.Forbidden)
+		return false
+	}
+	return true
+}
+
+// unauthorized 返回 401，并设置 WWW-Authenticate 响应头
+func (ctx *Context) unauthorized(challenge string) {
+	if challenge != "" {
+		ctx.Writer.Header().Set(_headerWWWAuthenticate, challenge)
+	}
+	ctx.AbortWithError(http.StatusUnauthorized, xerror.Unauthorized)
+}
+
+// BasicAuthConfig HTTP Basic 认证配置
+type BasicAuthConfig struct {
+	// Realm 认证域，默认为 Restricted
+	Realm string
+	// Validator 检查用户名与密码，必须设置
+	Validator func(ctx *Context, username, password string) bool
+	// Authorize 认证通过后检查权限，返回 false 时响应 403，为 nil 时不检查
+	Authorize func(ctx *Context, claims Claims) bool
+}
+
+// BasicAuth HTTP Basic 认证中间件
+// 认证失败返回 401，认证通过后用户名作为 sub 保存在 ctx.Claims() 中
+func BasicAuth(conf *BasicAuthConfig) Handler {
+	if conf.Validator == nil {
+		panic("basic auth: validator must not be nil")
+	}
+	realm := conf.Realm
+	if realm == "" {
+		realm = "Restricted"
+	}
+	challenge := "Basic realm=" + strconv.Quote(realm)
+	return func(ctx *Context) {
+		username, password, ok := ctx.Request.BasicAuth()
+		if !ok || !conf.Validator(ctx, username, password) {
+			ctx.unauthorized(challenge)
+			return
+		}
+		ctx.setClaims(Claims{"sub": username}, conf.Authorize)
+	}
+}
+
+// BasicAuthAccounts 返回检查固定账号密码的 Validator，使用常量时间比较密码
+func BasicAuthAccounts(accounts map[string]string) func(*Context, string, string) bool {
+	return func(_ *Context, username, password string) bool {
+		expected, ok := accounts[username]
+		if !ok {
+			return false
+		}
+		return subtle.ConstantTimeCompare([]byte(expected), []byte(password)) == 1
+	}
+}
+
+// APIKeyConfig API key 认证配置
+type APIKeyConfig struct {
+	// Header 读取 key 的请求头，默认为 X-API-Key
+	Header string
+	// Query 请求头中没有 key 时读取的 query 参数，为空时不从 query 读取
+	Query string
+	// Validator 检查 key，返回 key 对应的身份信息，必须设置
+	Validator func(ctx *Context, key string) (Claims, bool)
+	// Authorize 认证通过后检查权限，返回 false 时响应 403，为 nil 时不检查
+	Authorize func(ctx *Context, claims Claims) bool
+}
+
+// APIKey API key 认证中间件
+// 认证失败返回 401，认证通过后 Validator 返回的身份信息保存在 ctx.Claims() 中
+func APIKey(conf *APIKeyConfig) Handler {
+	if conf.Validator == nil {
+		panic("api key: validator must not be nil")
+	}
+	header := conf.Header
+	if header == "" {
+		header = _headerAPIKey
+	}
+	return func(ctx *Context) {
+		key := ctx.Request.Header.Get(header)
+		if key == "" && conf.Query != "" {
+			key = ctx.Request.URL.Query().Get(conf.Query)
+		}
+		if key == "" {
+			ctx.unauthorized("")
+			return
+		}
+		claims, ok := conf.Validator(ctx, key)
+		if !ok {
+			ctx.unauthorized("")
+			return
+		}
+		if claims == nil {
+			claims = Claims{}
+		}
+		ctx.setClaims(claims, conf.Authorize)
+	}
+}
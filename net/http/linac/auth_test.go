@@ -0,0 +1,90 @@
+package linac
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBasicAuth(t *testing.T) {
+	engine := NewEngine()
+	engine.Use(BasicAuth(&BasicAuthConfig{
+		Realm:     "linac",
+		Validator: BasicAuthAccounts(map[string]string{"admin": "secret", "guest": "guest"}),
+		Authorize: func(ctx *Context, claims Claims) bool {
+			return ctx.Request.Method == http.MethodGet || claims.Subject() == "admin"
+		},
+	}))
+	handler := func(ctx *Context) {
+		ctx.String(http.StatusOK, ctx.Claims().Subject())
+	}
+	engine.GET("/basic", "auth.basic.get", handler)
+	engine.POST("/basic", "auth.basic.post", handler)
+
+	t.Run("missing credentials", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/basic", nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Basic realm="linac"`, w.Header().Get("WWW-Authenticate"))
+		assert.Contains(t, w.Body.String(), `"code":401`)
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/basic", nil)
+		req.SetBasicAuth("admin", "wrong")
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("authenticated", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/basic", nil)
+		req.SetBasicAuth("guest", "guest")
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "guest", w.Body.String())
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/basic", nil)
+		req.SetBasicAuth("guest", "guest")
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":403`)
+	})
+}
+
+func TestAPIKey(t *testing.T) {
+	engine := NewEngine()
+	engine.Use(APIKey(&APIKeyConfig{
+		Query: "api_key",
+		Validator: func(ctx *Context, key string) (Claims, bool) {
+			if key != "k1" {
+				return nil, false
+			}
+			return Claims{"sub": "service-a"}, true
+		},
+	}))
+	engine.GET("/key", "auth.key", func(ctx *Context) {
+		ctx.String(http.StatusOK, ctx.Claims().Subject())
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/key", nil)
+	req.Header.Set("X-API-Key", "k1")
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "service-a", w.Body.String())
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/key?api_key=k1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/key?api_key=k2", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,238 @@
+package linac
+
+import (
+	xerror "linac/error"
+	"linac/stat/metric"
+	"linac/stat/sys/cpu"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	_defaultBBRConfig = &BBRConfig{
+		Name:         "default",
+		Window:       time.Second * time.Duration(10),
+		Bucket:       100,
+		CPUThreshold: 800,
+	}
+
+	_metricBBR = metric.NewGaugeVec(&metric.VectorOpts{
+		Namespace: _metricNamespace,
+		Subsystem: "bbr",
+		Name:      "stat",
+		Help:      "bbr load shedding limiter stat.",
+		Labels:    []string{"name", "stat"},
+	})
+	_metricBBRDropped = metric.NewCounterVec(&metric.VectorOpts{
+		Namespace: _metricNamespace,
+		Subsystem: "bbr",
+		Name:      "dropped_total",
+		Help:      "bbr load shedding limiter dropped requests total.",
+		Labels:    []string{"name"},
+	})
+)
+
+// BBRConfig 自适应限流配置
+type BBRConfig struct {
+	// Name 限流器名称，用于区分不同 engine、路由分组的指标
+	Name string
+	// Window 统计窗口
+	Window time.Duration
+	// Bucket 统计窗口的桶数
+	Bucket int
+	// CPUThreshold CPU 使用率阈值 (千分比)，超过时开始限流
+	CPUThreshold int64
+}
+
+// BBRStat 限流器的状态
+type BBRStat struct {
+	// CPU 使用率 (千分比)
+	CPU int64
+	// InFlight 正在处理的请求数
+	InFlight int64
+	// MaxPass 单个桶内最大的请求通过数
+	MaxPass int64
+	// MinRT 单个桶内最小的平均响应时间 (毫秒)
+	MinRT int64
+	// MaxInFlight 估算的系统最大并发数
+	MaxInFlight int64
+}
+
+// BBR 基于 CPU 使用率和并发数的自适应限流器
+// CPU 使用率超过阈值，且正在处理的请求数超过估算的最大并发数 (MaxPass * MinRT) 时拒绝请求。
+// 拒绝请求后 1 秒内即使 CPU 降到阈值以下也继续按并发数限流，避免抖动
+type BBR struct {
+	conf      *BBRConfig
+	cpu       func() int64
+	bucketDur time.Duration
+
+	inFlight     int64
+	prevDropTime int64 // NOTE: unix nano, 0 表示没有拒绝过请求
+
+	mu          sync.Mutex
+	buckets     []bbrBucket
+	offset      int
+	lastBucket  time.Time
+	cacheBucket time.Time
+	maxPass     int64
+	minRT       int64
+}
+
+type bbrBucket struct {
+	pass  int64
+	rt    int64
+	count int64
+}
+
+// NewBBR 返回自适应限流器，conf 为 nil 时使用默认配置
+func NewBBR(conf *BBRConfig) *BBR {
+	if conf == nil {
+		conf = _defaultBBRConfig
+	}
+	if conf.Window <= 0 || conf.Bucket <= 0 {
+		panic("bbr: window and bucket must greater than zero")
+	}
+	return &BBR{
+		conf:       conf,
+		cpu:        cpu.Usage,
+		bucketDur:  conf.Window / time.Duration(conf.Bucket),
+		buckets:    make([]bbrBucket, conf.Bucket),
+		lastBucket: time.Now(),
+	}
+}
+
+// Allow 检查是否允许请求通过，通过时请求处理完成后必须调用 done
+func (l *BBR) Allow() (done func(), err error) {
+	if l.shouldDrop() {
+		_metricBBRDropped.Inc(l.conf.Name)
+		l.report()
+		return nil, xerror.ServiceUnavailable
+	}
+	atomic.AddInt64(&l.inFlight, 1)
+	l.report()
+	start := time.Now()
+	return func() {
+		rt := int64(math.Ceil(float64(time.Since(start)) / float64(time.Millisecond)))
+		atomic.AddInt64(&l.inFlight, -1)
+		l.mu.Lock()
+		b := l.current(time.Now())
+		b.pass++
+		b.rt += rt
+		b.count++
+		l.mu.Unlock()
+		l.report()
+	}, nil
+}
+
+// Stat 返回限流器当前的状态
+func (l *BBR) Stat() BBRStat {
+	maxPass, minRT := l.passAndRT()
+	return BBRStat{
+		CPU:         l.cpu(),
+		InFlight:    atomic.LoadInt64(&l.inFlight),
+		MaxPass:     maxPass,
+		MinRT:       minRT,
+		MaxInFlight: l.maxInFlight(maxPass, minRT),
+	}
+}
+
+// report 更新限流器状态的指标
+func (l *BBR) report() {
+	stat := l.Stat()
+	_metricBBR.Set(float64(stat.CPU), l.conf.Name, "cpu")
+	_metricBBR.Set(float64(stat.InFlight), l.conf.Name, "in_flight")
+	_metricBBR.Set(float64(stat.MaxPass), l.conf.Name, "max_pass")
+	_metricBBR.Set(float64(stat.MinRT), l.conf.Name, "min_rt")
+	_metricBBR.Set(float64(stat.MaxInFlight), l.conf.Name, "max_in_flight")
+}
+
+func (l *BBR) shouldDrop() bool {
+	now := time.Now().UnixNano()
+	inFlight := atomic.LoadInt64(&l.inFlight)
+	if l.cpu() < l.conf.CPUThreshold {
+		prevDrop := atomic.LoadInt64(&l.prevDropTime)
+		if prevDrop == 0 {
+			return false
+		}
+		if time.Duration(now-prevDrop) <= time.Second {
+			return inFlight > 1 && inFlight > l.Stat().MaxInFlight
+		}
+		atomic.StoreInt64(&l.prevDropTime, 0)
+		return false
+	}
+	drop := inFlight > 1 && inFlight > l.Stat().MaxInFlight
+	if drop {
+		atomic.CompareAndSwapInt64(&l.prevDropTime, 0, now)
+	}
+	return drop
+}
+
+func (l *BBR) maxInFlight(maxPass, minRT int64) int64 {
+	bucketPerSecond := float64(time.Second) / float64(l.bucketDur)
+	return int64(math.Floor(float64(maxPass*minRT)*bucketPerSecond/1000 + 0.5))
+}
+
+// passAndRT 返回窗口内 (不含当前桶) 的最大通过数与最小平均响应时间，每个桶周期只计算一次
+func (l *BBR) passAndRT() (maxPass, minRT int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.current(time.Now())
+	if l.cacheBucket.Equal(l.lastBucket) {
+		return l.maxPass, l.minRT
+	}
+	maxPass, minRT = 1, math.MaxInt64
+	for i, b := range l.buckets {
+		if i == l.offset {
+			continue
+		}
+		if b.pass > maxPass {
+			maxPass = b.pass
+		}
+		if b.count > 0 {
+			if rt := int64(math.Ceil(float64(b.rt) / float64(b.count))); rt < minRT {
+				minRT = rt
+			}
+		}
+	}
+	if minRT == math.MaxInt64 {
+		minRT = 1
+	}
+	l.cacheBucket, l.maxPass, l.minRT = l.lastBucket, maxPass, minRT
+	return
+}
+
+// current 返回当前时间所在的桶，并清空已经过期的桶，调用方需持有锁
+func (l *BBR) current(now time.Time) *bbrBucket {
+	span := int(now.Sub(l.lastBucket) / l.bucketDur)
+	if span > 0 {
+		if span > len(l.buckets) {
+			span = len(l.buckets)
+		}
+		for i := 1; i <= span; i++ {
+			l.buckets[(l.offset+i)%len(l.buckets)] = bbrBucket{}
+		}
+		l.offset = (l.offset + span) % len(l.buckets)
+		l.lastBucket = l.lastBucket.Add(time.Duration(int(now.Sub(l.lastBucket)/l.bucketDur)) * l.bucketDur)
+	}
+	return &l.buckets[l.offset]
+}
+
+// LoadShedding 自适应限流中间件
+// 系统过载时以 503 拒绝请求，可以用于 engine 或路由分组，limiter 为 nil 时使用默认配置
+func LoadShedding(limiter *BBR) Handler {
+	if limiter == nil {
+		limiter = NewBBR(nil)
+	}
+	return func(ctx *Context) {
+		done, err := limiter.Allow()
+		if err != nil {
+			ctx.AbortWithError(http.StatusServiceUnavailable, err)
+			return
+		}
+		defer done()
+		ctx.Next()
+	}
+}
@@ -0,0 +1,117 @@
+package linac
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBBR(t *testing.T) {
+	t.Run("pass when cpu below threshold", func(t *testing.T) {
+		l := NewBBR(&BBRConfig{Name: "test_low", Window: time.Second, Bucket: 10, CPUThreshold: 800})
+		l.cpu = func() int64 { return 100 }
+		var dones []func()
+		for i := 0; i < 100; i++ {
+			done, err := l.Allow()
+			assert.Nil(t, err)
+			dones = append(dones, done)
+		}
+		assert.Equal(t, int64(100), l.Stat().InFlight)
+		// CPU 低于阈值时指标同样更新
+		assert.Equal(t, float64(100), _metricBBR.Value("test_low", "in_flight"))
+		assert.Equal(t, float64(100), _metricBBR.Value("test_low", "cpu"))
+		for _, done := range dones {
+			done()
+		}
+		assert.Equal(t, int64(0), l.Stat().InFlight)
+		assert.Equal(t, float64(0), _metricBBR.Value("test_low", "in_flight"))
+	})
+
+	t.Run("drop when overloaded", func(t *testing.T) {
+		l := NewBBR(&BBRConfig{Name: "test_high", Window: time.Second, Bucket: 10, CPUThreshold: 800})
+		l.cpu = func() int64 { return 900 }
+		var (
+			dones   []func()
+			dropped int
+		)
+		for i := 0; i < 10; i++ {
+			done, err := l.Allow()
+			if err != nil {
+				dropped++
+				continue
+			}
+			dones = append(dones, done)
+		}
+		assert.Equal(t, 8, dropped)
+		assert.Equal(t, int64(2), l.Stat().InFlight)
+		assert.Equal(t, float64(2), _metricBBR.Value("test_high", "in_flight"))
+		assert.Equal(t, float64(900), _metricBBR.Value("test_high", "cpu"))
+		for _, done := range dones {
+			done()
+		}
+
+		// 降到阈值以下后 1 秒内仍然按并发数限流
+		l.cpu = func() int64 { return 100 }
+		done1, err := l.Allow()
+		assert.Nil(t, err)
+		done2, err := l.Allow()
+		assert.Nil(t, err)
+		_, err = l.Allow()
+		assert.NotNil(t, err)
+		done1()
+		done2()
+	})
+
+	t.Run("max in flight", func(t *testing.T) {
+		l := NewBBR(&BBRConfig{Name: "test_estimate", Window: time.Second, Bucket: 10, CPUThreshold: 800})
+		assert.Equal(t, int64(1), l.maxInFlight(5, 20))
+		// 每个桶 100ms，最多通过 50 个请求，最小响应时间 20ms => 每秒 500 QPS * 0.02s = 10
+		assert.Equal(t, int64(10), l.maxInFlight(50, 20))
+	})
+}
+
+func TestLoadShedding(t *testing.T) {
+	l := NewBBR(&BBRConfig{Name: "test_middleware", Window: time.Second, Bucket: 10, CPUThreshold: 800})
+	l.cpu = func() int64 { return 900 }
+
+	engine := NewEngine()
+	engine.Use(LoadShedding(l))
+	block := make(chan struct{})
+	engine.GET("/slow", "bbr.slow", func(ctx *Context) {
+		<-block
+		ctx.String(http.StatusOK, "ok")
+	})
+
+	var (
+		wg    sync.WaitGroup
+		codes = make(chan int, 5)
+	)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+			codes <- w.Code
+		}()
+	}
+	for l.Stat().InFlight < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":503`)
+
+	close(block)
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		assert.Equal(t, http.StatusOK, code)
+	}
+}
@@ -0,0 +1,88 @@
+package linac
+
+import (
+	"encoding/json"
+	"fmt"
+	xerror "linac/error"
+	"linac/net/http/linac/render"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+const (
+	_mimeJSON     = "application/json"
+	_mimeMsgPack  = "application/msgpack"
+	_mimeXMsgPack = "application/x-msgpack"
+)
+
+// Bind 根据请求的 Content-Type 将请求体解码到 obj 中
+// 支持 application/json 和 application/msgpack(application/x-msgpack)，
+// 请求体超过 MaxRequestBody 时返回 xerror.RequestEntityTooLarge
+func (ctx *Context) Bind(obj interface{}) error {
+	req := ctx.Request
+	if req.Body == nil {
+		return fmt.Errorf("bind: empty request body")
+	}
+	ctype, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("bind: invalid content type: %v", err)
+	}
+	switch ctype {
+	case _mimeJSON:
+		err = json.NewDecoder(req.Body).Decode(obj)
+	case _mimeMsgPack, _mimeXMsgPack:
+		err = render.NewMsgPackDecoder(req.Body).Decode(obj)
+	default:
+		return fmt.Errorf("bind: unsupported content type %s", ctype)
+	}
+	if isBodyTooLarge(err) {
+		return xerror.RequestEntityTooLarge
+	}
+	return err
+}
+
+// negotiate 根据 Accept 头从 offers 中选择 q 值最高的类型，q 值相同时靠前的优先
+// Accept 为空时返回 offers[0]，没有可接受的类型时返回空字符串
+func negotiate(accept string, offers ...string) string {
+	if accept == "" {
+		return offers[0]
+	}
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := acceptQuality(accept, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// acceptQuality 返回 offer 在 Accept 中的 q 值，以最精确匹配的媒体范围为准
+func acceptQuality(accept, offer string) float64 {
+	q, specificity := 0.0, -1
+	for _, spec := range strings.Split(accept, ",") {
+		mtype, params, err := mime.ParseMediaType(strings.TrimSpace(spec))
+		if err != nil {
+			continue
+		}
+		s := -1
+		switch {
+		case mtype == offer, offer == _mimeMsgPack && mtype == _mimeXMsgPack:
+			s = 2
+		case mtype == "*/*":
+			s = 0
+		case strings.HasSuffix(mtype, "/*") && strings.HasPrefix(offer, mtype[:len(mtype)-1]):
+			s = 1
+		}
+		if s <= specificity {
+			continue
+		}
+		specificity, q = s, 1.0
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+	}
+	return q
+}
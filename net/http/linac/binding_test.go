@@ -0,0 +1,76 @@
+package linac
+
+import (
+	"bytes"
+	"linac/net/http/linac/render"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	cases := map[string]string{
+		"":                      _mimeJSON,
+		"*/*":                   _mimeJSON,
+		"application/msgpack":   _mimeMsgPack,
+		"application/x-msgpack": _mimeMsgPack,
+		"application/*;q=0.5, application/msgpack": _mimeMsgPack,
+		"application/msgpack;q=0.4, */*;q=0.8":     _mimeJSON,
+		"application/json;q=0, application/*":      _mimeMsgPack,
+		"text/html":                                "",
+	}
+	for accept, expected := range cases {
+		assert.Equal(t, expected, negotiate(accept, _mimeJSON, _mimeMsgPack), accept)
+	}
+}
+
+func TestMsgPackBinding(t *testing.T) {
+	type payload struct {
+		Name string `json:"name"`
+		Tags []string
+	}
+	engine := NewEngine()
+	engine.POST("/echo", "echo", func(ctx *Context) {
+		var p payload
+		err := ctx.Bind(&p)
+		ctx.Negotiate(p, err)
+	})
+
+	t.Run("Should bind and render msgpack", func(t *testing.T) {
+		body, err := render.MarshalMsgPack(payload{Name: "linac", Tags: []string{"a"}})
+		assert.Nil(t, err)
+		req := httptest.NewRequest("POST", "/echo", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/msgpack")
+		req.Header.Set("Accept", "application/msgpack")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/msgpack", w.Header().Get("Content-Type"))
+		var out struct {
+			Code int
+			Data payload
+		}
+		assert.Nil(t, render.UnmarshalMsgPack(w.Body.Bytes(), &out))
+		assert.Equal(t, payload{Name: "linac", Tags: []string{"a"}}, out.Data)
+	})
+
+	t.Run("Should bind json and fall back to json", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/echo", bytes.NewBufferString(`{"name":"linac"}`))
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, "{\"code\":0,\"err\":\"0\",\"data\":{\"name\":\"linac\",\"Tags\":null}}", w.Body.String())
+	})
+
+	t.Run("Should reject deeply nested msgpack", func(t *testing.T) {
+		body := append(bytes.Repeat([]byte{0x91}, 1<<20-1), 0xc0)
+		req := httptest.NewRequest("POST", "/echo", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/msgpack")
+		var v interface{}
+		ctx := &Context{Request: req}
+		assert.NotNil(t, ctx.Bind(&v))
+	})
+}
@@ -0,0 +1,96 @@
+package linac
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	xerror "linac/error"
+	"net/http"
+	"strings"
+)
+
+const (
+	// _defaultMaxMultipartMemory 解析 multipart 表单时默认保存在内存中的最大字节数，超过的文件写入临时文件
+	_defaultMaxMultipartMemory = 32 << 20 // 32M
+)
+
+// errBodyTooLarge Content-Length 超过限制时直接返回的错误
+var errBodyTooLarge = errors.New("http: request body too large")
+
+// limitBody 使用 http.MaxBytesReader 限制请求体大小，Content-Length 已经超过限制时直接返回错误
+// maxRequestBody <= 0 时不限制
+func (ctx *Context) limitBody() error {
+	req := ctx.Request
+	if ctx.maxRequestBody <= 0 || req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	if req.ContentLength > ctx.maxRequestBody {
+		return errBodyTooLarge
+	}
+	req.Body = http.MaxBytesReader(ctx.Writer, req.Body, ctx.maxRequestBody)
+	return nil
+}
+
+// readBody 读取完整的请求体，并替换为可重复读取的副本
+func (ctx *Context) readBody() ([]byte, error) {
+	req := ctx.Request
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// parseMultipartForm 解析 multipart 表单，同时计算原始请求体的摘要
+// multipart 请求体解析后无法再次读取，签名校验等中间件通过 bodyHash 使用该摘要
+func (ctx *Context) parseMultipartForm() error {
+	req := ctx.Request
+	if req.Body == nil || req.Body == http.NoBody {
+		return req.ParseMultipartForm(ctx.maxMultipartMemory)
+	}
+	body, h := req.Body, sha256.New()
+	req.Body = ioutil.NopCloser(io.TeeReader(body, h))
+	defer func() { req.Body = body }()
+	if err := req.ParseMultipartForm(ctx.maxMultipartMemory); err != nil {
+		return err
+	}
+	// 结束边界之后的内容同样属于请求体
+	if _, err := io.Copy(ioutil.Discard, req.Body); err != nil {
+		return err
+	}
+	ctx.multipartHash = hex.EncodeToString(h.Sum(nil))
+	return nil
+}
+
+// bodyHash 返回请求体 SHA-256 摘要的十六进制编码
+// multipart 请求返回解析表单时计算的摘要，其余请求读取请求体计算
+func (ctx *Context) bodyHash() (string, error) {
+	if ctx.multipartHash != "" {
+		return ctx.multipartHash, nil
+	}
+	body, err := ctx.readBody()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// isBodyTooLarge 判断是否为请求体超过限制的错误
+// NOTE: http.MaxBytesReader 返回的错误在 go1.19 之前没有导出类型，只能比较错误信息
+func isBodyTooLarge(err error) bool {
+	return err != nil && strings.Contains(err.Error(), errBodyTooLarge.Error())
+}
+
+// requestEntityTooLarge 请求体超过限制时返回 413
+func requestEntityTooLarge(ctx *Context) {
+	ctx.AbortWithError(http.StatusRequestEntityTooLarge, xerror.RequestEntityTooLarge)
+}
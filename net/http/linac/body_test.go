@@ -0,0 +1,107 @@
+package linac
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxRequestBody(t *testing.T) {
+	engine := NewEngine()
+	engine.SetConfig(&ServerConfig{MaxRequestBody: 16})
+	engine.POST("/json", "body.json", func(ctx *Context) {
+		var v map[string]interface{}
+		if err := ctx.Bind(&v); err != nil {
+			ctx.AbortWithError(http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		ctx.String(http.StatusOK, "ok")
+	})
+	engine.POST("/form", "body.form", func(ctx *Context) {
+		ctx.String(http.StatusOK, "%v", ctx.Post("a"))
+	})
+	engine.POST("/upload", "body.upload", func(ctx *Context) {
+		_, fh, err := ctx.Request.FormFile("file")
+		if err != nil {
+			ctx.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		ctx.String(http.StatusOK, "%d", fh.Size)
+	}).SetConfig(&RouteConfig{MaxUploadSize: 1 << 10, MaxMultipartMemory: 64})
+	engine.POST("/unlimited", "body.unlimited", func(ctx *Context) {
+		bs, _ := ioutil.ReadAll(ctx.Request.Body)
+		ctx.String(http.StatusOK, "%d", len(bs))
+	}).SetConfig(&RouteConfig{})
+
+	t.Run("route without limit", func(t *testing.T) {
+		// 路由配置的 MaxRequestBody 为 0 时不限制，不使用服务器配置
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/unlimited", strings.NewReader(strings.Repeat("a", 64))))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "64", w.Body.String())
+	})
+
+	t.Run("content length", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/json", strings.NewReader(`{"a":"0123456789abcdef"}`))
+		req.Header.Set("Content-Type", "application/json")
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":413`)
+	})
+
+	t.Run("chunked", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/json", ioutil.NopCloser(strings.NewReader(`{"a":"0123456789abcdef"}`)))
+		req.ContentLength = -1
+		req.Header.Set("Content-Type", "application/json")
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":413`)
+	})
+
+	t.Run("within limit", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/json", strings.NewReader(`{"a":1}`))
+		req.Header.Set("Content-Type", "application/json")
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("form", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/form", ioutil.NopCloser(strings.NewReader("a=0123456789abcdef")))
+		req.ContentLength = -1
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+
+	upload := func(size int) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		fw, _ := mw.CreateFormFile("file", "a.txt")
+		fw.Write(bytes.Repeat([]byte("x"), size))
+		mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/upload", &buf)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("upload", func(t *testing.T) {
+		w := upload(512)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "512", w.Body.String())
+
+		w = upload(2 << 10)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+}
@@ -0,0 +1,326 @@
+package linac
+
+import (
+	"container/list"
+	"linac/stat/metric"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	_headerCacheControl = "Cache-Control"
+	_headerXCache       = "X-Cache"
+
+	// _defaultCacheSize 默认缓存的最大字节数
+	_defaultCacheSize = 64 << 20 // 64M
+	// _defaultCacheMaxBody 默认缓存的单个响应体最大字节数
+	_defaultCacheMaxBody = 1 << 20 // 1M
+)
+
+var (
+	// DefaultResponseCache Cache 中间件默认使用的缓存
+	DefaultResponseCache = NewResponseCache(_defaultCacheSize)
+
+	_defaultCacheConfig = &CacheConfig{
+		Store:   DefaultResponseCache,
+		MaxBody: _defaultCacheMaxBody,
+	}
+
+	_metricCache = metric.NewCounterVec(&metric.VectorOpts{
+		Namespace: _metricNamespace,
+		Subsystem: "http_cache",
+		Name:      "requests_total",
+		Help:      "http response cache requests total.",
+		Labels:    []string{"route", "result"},
+	})
+)
+
+// CacheConfig 响应缓存中间件配置
+type CacheConfig struct {
+	// Store 缓存，默认为 DefaultResponseCache
+	Store *ResponseCache
+	// TTL 默认的缓存时间，响应的 Cache-Control 中有 s-maxage 或 max-age 时优先使用
+	TTL time.Duration
+	// KeyFunc 缓存的 key，默认为请求的 path 与 query
+	KeyFunc func(*Context) string
+	// VaryHeaders 缓存需要区分的请求头，如 Accept、Accept-Language
+	VaryHeaders []string
+	// MaxBody 可以缓存的响应体最大字节数，默认 1M
+	MaxBody int
+}
+
+// Cache 响应缓存中间件，使用 DefaultResponseCache
+// keyFunc 为 nil 时使用请求的 path 与 query 作为 key
+func Cache(ttl time.Duration, keyFunc func(*Context) string) Handler {
+	return CacheWithConfig(&CacheConfig{TTL: ttl, KeyFunc: keyFunc})
+}
+
+// CacheWithConfig 响应缓存中间件
+// 只缓存 GET 请求的 200 响应。请求的 Cache-Control 为 no-store 时不使用缓存，
+// 为 no-cache 或 max-age=0 时不读取缓存但会更新缓存；响应的 Cache-Control 为 no-store、no-cache、private，
+// 或者响应设置了 cookie 时不缓存，携带 Authorization 的请求只有响应为 public 时才缓存。
+// 响应头 X-Cache 为 HIT 或 MISS。
+// conf 为 nil 时使用默认配置，没有默认的缓存时间，只缓存响应的 Cache-Control 中有 s-maxage 或 max-age 的响应
+func CacheWithConfig(conf *CacheConfig) Handler {
+	if conf == nil {
+		conf = _defaultCacheConfig
+	}
+	store := conf.Store
+	if store == nil {
+		store = DefaultResponseCache
+	}
+	keyFunc := conf.KeyFunc
+	if keyFunc == nil {
+		keyFunc = func(ctx *Context) string { return ctx.Request.URL.RequestURI() }
+	}
+	maxBody := conf.MaxBody
+	if maxBody <= 0 {
+		maxBody = _defaultCacheMaxBody
+	}
+	return func(ctx *Context) {
+		req := ctx.Request
+		if req.Method != http.MethodGet {
+			return
+		}
+		reqCC := parseCacheControl(req.Header.Get(_headerCacheControl))
+		if _, ok := reqCC["no-store"]; ok {
+			return
+		}
+		route := ctx.RouteName()
+		userKey := keyFunc(ctx)
+		key := cacheKey(route, userKey, req.Header, conf.VaryHeaders)
+		header := ctx.Writer.Header()
+		for _, h := range conf.VaryHeaders {
+			addVary(header, h)
+		}
+
+		_, noCache := reqCC["no-cache"]
+		if !noCache && reqCC["max-age"] != "0" {
+			if resp, ok := store.get(key); ok {
+				_metricCache.Inc(route, "hit")
+				resp.writeTo(ctx, store.now())
+				return
+			}
+		}
+		_metricCache.Inc(route, "miss")
+		header.Set(_headerXCache, "MISS")
+
+		w := newRecordWriter(ctx.Writer, maxBody)
+		ctx.Writer = w
+		defer func() {
+			ctx.Writer = w.ResponseWriter
+		}()
+		ctx.Next()
+		status, respHeader, body, ok := w.recorded()
+		if !ok || status != http.StatusOK {
+			return
+		}
+		ttl, ok := responseTTL(respHeader, conf.TTL, req.Header.Get(_headerAuthorization) != "")
+		if !ok {
+			return
+		}
+		store.set(key, route, userKey, &cachedResponse{
+			status: status,
+			header: respHeader,
+			body:   append([]byte(nil), body...),
+		}, ttl)
+	}
+}
+
+// cacheKey 以路由名称、key 与 VaryHeaders 的值作为缓存的 key
+func cacheKey(route, key string, header http.Header, vary []string) string {
+	var b strings.Builder
+	b.WriteString(route)
+	b.WriteByte('|')
+	b.WriteString(key)
+	for _, h := range vary {
+		b.WriteByte('|')
+		b.WriteString(strings.Join(header.Values(h), ","))
+	}
+	return b.String()
+}
+
+// responseTTL 根据响应的 Cache-Control 返回缓存时间，不能缓存时返回 false
+func responseTTL(header http.Header, ttl time.Duration, authorized bool) (time.Duration, bool) {
+	if len(header.Values("Set-Cookie")) > 0 {
+		return 0, false
+	}
+	cc := parseCacheControl(header.Get(_headerCacheControl))
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := cc[d]; ok {
+			return 0, false
+		}
+	}
+	if _, ok := cc["public"]; authorized && !ok {
+		return 0, false
+	}
+	for _, d := range []string{"s-maxage", "max-age"} {
+		if v, ok := cc[d]; ok {
+			sec, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || sec <= 0 {
+				return 0, false
+			}
+			return time.Duration(sec) * time.Second, true
+		}
+	}
+	return ttl, ttl > 0
+}
+
+// parseCacheControl 解析 Cache-Control，指令名转为小写
+func parseCacheControl(cc string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(cc, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		name := strings.ToLower(strings.TrimSpace(kv[0]))
+		if len(kv) == 2 {
+			directives[name] = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		} else {
+			directives[name] = ""
+		}
+	}
+	return directives
+}
+
+// cachedResponse 缓存的响应
+type cachedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+
+	created time.Time
+}
+
+func (resp *cachedResponse) writeTo(ctx *Context, now time.Time) {
+	header := ctx.Writer.Header()
+	header.Set(_headerXCache, "HIT")
+	header.Set("Age", strconv.FormatInt(int64(now.Sub(resp.created)/time.Second), 10))
+	ctx.writeRecorded(resp.status, resp.header, resp.body)
+}
+
+func (resp *cachedResponse) size() int64 {
+	n := int64(len(resp.body))
+	for k, v := range resp.header {
+		n += int64(len(k))
+		for _, s := range v {
+			n += int64(len(s))
+		}
+	}
+	return n
+}
+
+// ResponseCache 内存响应缓存，总大小超过限制时淘汰最久未使用的响应
+type ResponseCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	ll       *list.List
+	items    map[string]*list.Element
+	now      func() time.Time
+}
+
+type cacheEntry struct {
+	key    string
+	route  string
+	user   string
+	resp   *cachedResponse
+	expire time.Time
+}
+
+// NewResponseCache 返回最多保存 maxBytes 字节的响应缓存
+func NewResponseCache(maxBytes int64) *ResponseCache {
+	return &ResponseCache{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+		now:      time.Now,
+	}
+}
+
+// get 返回未过期的响应
+func (c *ResponseCache) get(key string) (*cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if c.now().After(e.expire) {
+		c.remove(el)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return e.resp, true
+}
+
+// set 缓存响应，单个响应超过最大字节数时不缓存
+func (c *ResponseCache) set(key, route, userKey string, resp *cachedResponse, ttl time.Duration) {
+	size := resp.size()
+	if size > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	resp.created = now
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	c.items[key] = c.ll.PushFront(&cacheEntry{key: key, route: route, user: userKey, resp: resp, expire: now.Add(ttl)})
+	c.size += size
+	for c.size > c.maxBytes {
+		c.remove(c.ll.Back())
+	}
+}
+
+// InvalidateRoute 删除路由的所有缓存，返回删除的数量
+func (c *ResponseCache) InvalidateRoute(name string) int {
+	return c.invalidate(func(e *cacheEntry) bool { return e.route == name })
+}
+
+// InvalidatePrefix 删除 key (KeyFunc 的返回值) 以 prefix 开头的缓存，返回删除的数量
+func (c *ResponseCache) InvalidatePrefix(prefix string) int {
+	return c.invalidate(func(e *cacheEntry) bool { return strings.HasPrefix(e.user, prefix) })
+}
+
+// Len 返回缓存的响应数量
+func (c *ResponseCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// Size 返回缓存的总字节数
+func (c *ResponseCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+func (c *ResponseCache) invalidate(match func(*cacheEntry) bool) (n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for el := c.ll.Front(); el != nil; {
+		next := el.Next()
+		if match(el.Value.(*cacheEntry)) {
+			c.remove(el)
+			n++
+		}
+		el = next
+	}
+	return
+}
+
+// remove 删除缓存，调用方需持有锁
+func (c *ResponseCache) remove(el *list.Element) {
+	e := c.ll.Remove(el).(*cacheEntry)
+	delete(c.items, e.key)
+	c.size -= e.resp.size()
+}
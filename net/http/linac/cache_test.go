@@ -0,0 +1,171 @@
+package linac
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	var calls int32
+	store := NewResponseCache(1 << 20)
+	engine := NewEngine()
+	engine.Use(CacheWithConfig(&CacheConfig{
+		Store:       store,
+		TTL:         time.Minute,
+		VaryHeaders: []string{"Accept-Language"},
+	}))
+	engine.GET("/articles", "cache.articles", func(ctx *Context) {
+		n := atomic.AddInt32(&calls, 1)
+		if cc := ctx.Request.URL.Query().Get("cc"); cc != "" {
+			ctx.Writer.Header().Set("Cache-Control", cc)
+		}
+		ctx.String(http.StatusOK, "%s %d", ctx.Request.Header.Get("Accept-Language"), n)
+	})
+
+	get := func(target string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("hit", func(t *testing.T) {
+		w := get("/articles", nil)
+		assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+		assert.Equal(t, " 1", w.Body.String())
+		w = get("/articles", nil)
+		assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+		assert.Equal(t, " 1", w.Body.String())
+		assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, "Accept-Language", w.Header().Get("Vary"))
+	})
+
+	t.Run("vary", func(t *testing.T) {
+		w := get("/articles", http.Header{"Accept-Language": {"zh"}})
+		assert.Equal(t, "zh 2", w.Body.String())
+		w = get("/articles", http.Header{"Accept-Language": {"zh"}})
+		assert.Equal(t, "zh 2", w.Body.String())
+	})
+
+	t.Run("request cache control", func(t *testing.T) {
+		w := get("/articles", http.Header{"Cache-Control": {"no-cache"}})
+		assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+		assert.Equal(t, " 3", w.Body.String())
+		// no-cache 会更新缓存
+		assert.Equal(t, " 3", get("/articles", nil).Body.String())
+
+		w = get("/articles", http.Header{"Cache-Control": {"no-store"}})
+		assert.Equal(t, "", w.Header().Get("X-Cache"))
+		assert.Equal(t, " 4", w.Body.String())
+	})
+
+	t.Run("response cache control", func(t *testing.T) {
+		get("/articles?cc=private", nil)
+		assert.Equal(t, "MISS", get("/articles?cc=private", nil).Header().Get("X-Cache"))
+
+		get("/articles?cc=max-age%3D1", nil)
+		assert.Equal(t, "HIT", get("/articles?cc=max-age%3D1", nil).Header().Get("X-Cache"))
+		store.now = func() time.Time { return time.Now().Add(time.Second * 2) }
+		assert.Equal(t, "MISS", get("/articles?cc=max-age%3D1", nil).Header().Get("X-Cache"))
+		store.now = time.Now
+	})
+
+	t.Run("authorization", func(t *testing.T) {
+		auth := http.Header{"Authorization": {"Bearer t"}}
+		get("/articles?auth=1", auth)
+		assert.Equal(t, "MISS", get("/articles?auth=1", auth).Header().Get("X-Cache"))
+	})
+
+	t.Run("invalidate", func(t *testing.T) {
+		get("/articles", nil)
+		assert.Equal(t, "HIT", get("/articles", nil).Header().Get("X-Cache"))
+		assert.True(t, store.InvalidatePrefix("/articles") > 0)
+		assert.Equal(t, "MISS", get("/articles", nil).Header().Get("X-Cache"))
+		assert.True(t, store.InvalidateRoute("cache.articles") > 0)
+		assert.Equal(t, 0, store.Len())
+		assert.Equal(t, int64(0), store.Size())
+	})
+}
+
+func TestCacheDefaultConfig(t *testing.T) {
+	var calls int32
+	engine := NewEngine()
+	engine.Use(CacheWithConfig(nil))
+	defer DefaultResponseCache.InvalidateRoute("cache.default")
+	engine.GET("/cache-default", "cache.default", func(ctx *Context) {
+		n := atomic.AddInt32(&calls, 1)
+		if ctx.Request.URL.Query().Get("cc") != "" {
+			ctx.Writer.Header().Set("Cache-Control", "max-age=60")
+		}
+		ctx.String(http.StatusOK, "%d", n)
+	})
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	// 没有默认的缓存时间
+	assert.Equal(t, "MISS", get("/cache-default").Header().Get("X-Cache"))
+	assert.Equal(t, "MISS", get("/cache-default").Header().Get("X-Cache"))
+	assert.Equal(t, "MISS", get("/cache-default?cc=1").Header().Get("X-Cache"))
+	w := get("/cache-default?cc=1")
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	assert.Equal(t, "3", w.Body.String())
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestCacheOuterHeaders(t *testing.T) {
+	engine := NewEngine()
+	engine.Use(Trace(), Cache(time.Minute, nil))
+	defer DefaultResponseCache.InvalidateRoute("cache.trace")
+	engine.GET("/cache-trace", "cache.trace", func(ctx *Context) {
+		ctx.Writer.Header().Set("X-Handler", "1")
+		ctx.String(http.StatusOK, "ok")
+	})
+	get := func(requestID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/cache-trace", nil)
+		req.Header.Set(_headerRequestID, requestID)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, "MISS", get("req-1").Header().Get("X-Cache"))
+	w := get("req-2")
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	// 外层中间件设置的响应头不会被缓存的值覆盖
+	assert.Equal(t, "req-2", w.Header().Get(_headerRequestID))
+	assert.Equal(t, "1", w.Header().Get("X-Handler"))
+}
+
+func TestResponseCacheLRU(t *testing.T) {
+	c := NewResponseCache(250)
+	resp := func() *cachedResponse {
+		return &cachedResponse{status: http.StatusOK, header: http.Header{}, body: []byte(strings.Repeat("x", 100))}
+	}
+	c.set("a", "r", "a", resp(), time.Minute)
+	c.set("b", "r", "b", resp(), time.Minute)
+	_, ok := c.get("a")
+	assert.True(t, ok)
+	c.set("c", "r", "c", resp(), time.Minute)
+
+	_, ok = c.get("b")
+	assert.False(t, ok)
+	_, ok = c.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 2, c.Len())
+	assert.Equal(t, int64(200), c.Size())
+
+	c.set("big", "r", "big", &cachedResponse{body: make([]byte, 300)}, time.Minute)
+	assert.Equal(t, 2, c.Len())
+}
@@ -0,0 +1,195 @@
+package linac
+
+import (
+	xerror "linac/error"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// _defaultClientIPHeader 默认的客户端地址请求头
+	_defaultClientIPHeader = "X-Forwarded-For"
+)
+
+// ClientIP 返回客户端 IP
+// 直接连接的对端是 ServerConfig.TrustedProxies 中的可信代理时，只根据 ServerConfig.ClientIPHeader
+// (默认 X-Forwarded-For) 从右向左跳过可信代理，返回第一个不可信的地址；
+// 其余的转发请求头可能由客户端伪造，不会被读取
+func (ctx *Context) ClientIP() string {
+	req := ctx.Request
+	remote := remoteIP(req)
+	if remote == nil {
+		return remoteHost(req)
+	}
+	if !containsIP(ctx.trustedProxies, remote) {
+		return remote.String()
+	}
+	header := ctx.clientIPHeader
+	if header == "" {
+		header = _defaultClientIPHeader
+	}
+	var chain []string
+	if strings.EqualFold(header, "Forwarded") {
+		chain = forwardedFor(req.Header.Values(header))
+	} else {
+		chain = xForwardedFor(req.Header.Values(header))
+	}
+	client := remote
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := parseForwardedIP(chain[i])
+		if ip == nil {
+			// 无法解析的地址可能是伪造的，返回最后一个可信代理之前的地址
+			break
+		}
+		client = ip
+		if !containsIP(ctx.trustedProxies, ip) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// xForwardedFor 解析 X-Forwarded-For 等以逗号分隔的地址列表，多个请求头按出现顺序合并
+func xForwardedFor(values []string) (chain []string) {
+	for _, v := range values {
+		for _, addr := range strings.Split(v, ",") {
+			chain = append(chain, strings.TrimSpace(addr))
+		}
+	}
+	return
+}
+
+// forwardedFor 解析 RFC 7239 Forwarded 中的 for 参数
+func forwardedFor(values []string) (chain []string) {
+	for _, v := range values {
+		for _, elem := range strings.Split(v, ",") {
+			for _, pair := range strings.Split(elem, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+					chain = append(chain, strings.Trim(kv[1], `"`))
+				}
+			}
+		}
+	}
+	return
+}
+
+// parseForwardedIP 解析 "1.2.3.4"、"1.2.3.4:80"、"[::1]" 与 "[::1]:80" 格式的地址
+func parseForwardedIP(addr string) net.IP {
+	if ip := net.ParseIP(addr); ip != nil {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return net.ParseIP(host)
+	}
+	return net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]"))
+}
+
+// remoteHost 返回 RemoteAddr 的 host 部分
+func remoteHost(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// remoteIP 返回直接连接的对端 IP
+func remoteIP(req *http.Request) net.IP {
+	return net.ParseIP(remoteHost(req))
+}
+
+// parseCIDRs 解析 IP 或 CIDR 列表
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, s := range cidrs {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, &net.ParseError{Type: "IP address", Text: s}
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IPFilter IP 黑白名单，规则可以在运行时通过 Update 更新
+type IPFilter struct {
+	rules atomic.Value // NOTE: struct *ipRules
+}
+
+type ipRules struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// NewIPFilter 返回 IP 黑白名单，allow 与 deny 为 IP 或 CIDR 列表
+func NewIPFilter(allow, deny []string) (*IPFilter, error) {
+	f := &IPFilter{}
+	if err := f.Update(allow, deny); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Update 替换黑白名单，解析失败时保留原来的规则
+func (f *IPFilter) Update(allow, deny []string) error {
+	allowNets, err := parseCIDRs(allow)
+	if err != nil {
+		return err
+	}
+	denyNets, err := parseCIDRs(deny)
+	if err != nil {
+		return err
+	}
+	f.rules.Store(&ipRules{allow: allowNets, deny: denyNets})
+	return nil
+}
+
+// Allowed 检查 IP 是否允许访问
+// 匹配黑名单时拒绝，白名单不为空且未匹配白名单时拒绝
+func (f *IPFilter) Allowed(ip net.IP) bool {
+	rules, _ := f.rules.Load().(*ipRules)
+	if ip == nil || rules == nil {
+		return rules == nil
+	}
+	if containsIP(rules.deny, ip) {
+		return false
+	}
+	return len(rules.allow) == 0 || containsIP(rules.allow, ip)
+}
+
+// FilterIP IP 黑白名单中间件，使用 ctx.ClientIP() 检查，拒绝的请求返回 403
+// 可以用于 engine 或路由分组，不同分组可以使用不同的 IPFilter
+func FilterIP(filter *IPFilter) Handler {
+	return func(ctx *Context) {
+		if !filter.Allowed(net.ParseIP(ctx.ClientIP())) {
+			ctx.AbortWithError(http.StatusForbidden, xerror.Forbidden)
+		}
+	}
+}
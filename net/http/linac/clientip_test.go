@@ -0,0 +1,88 @@
+package linac
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies, err := parseCIDRs([]string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"})
+	assert.Nil(t, err)
+
+	cases := []struct {
+		name     string
+		remote   string
+		ipHeader string
+		header   http.Header
+		ip       string
+	}{
+		{name: "direct", remote: "1.2.3.4:1234", ip: "1.2.3.4"},
+		{name: "untrusted remote", remote: "1.2.3.4:1234", header: http.Header{"X-Forwarded-For": {"5.6.7.8"}}, ip: "1.2.3.4"},
+		{name: "two proxies", remote: "10.0.0.1:1234", header: http.Header{"X-Forwarded-For": {"9.9.9.9, 5.6.7.8, 192.168.1.1"}}, ip: "5.6.7.8"},
+		{name: "multiple headers", remote: "10.0.0.1:1234", header: http.Header{"X-Forwarded-For": {"5.6.7.8", "10.0.0.2"}}, ip: "5.6.7.8"},
+		{name: "all trusted", remote: "10.0.0.1:1234", header: http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}}, ip: "10.0.0.3"},
+		{name: "invalid entry", remote: "10.0.0.1:1234", header: http.Header{"X-Forwarded-For": {"5.6.7.8, bogus, 10.0.0.2"}}, ip: "10.0.0.2"},
+		{name: "forwarded", remote: "10.0.0.1:1234", ipHeader: "Forwarded", header: http.Header{"Forwarded": {`for="[2001:db8::1]:4711";proto=https, for=10.0.0.2`}}, ip: "2001:db8::1"},
+		{name: "real ip", remote: "[fd00::1]:1234", ipHeader: "X-Real-IP", header: http.Header{"X-Real-Ip": {"5.6.7.8"}}, ip: "5.6.7.8"},
+		{name: "spoofed forwarded", remote: "10.0.0.1:1234", header: http.Header{"Forwarded": {"for=9.9.9.9"}, "X-Forwarded-For": {"5.6.7.8"}}, ip: "5.6.7.8"},
+		{name: "spoofed real ip", remote: "10.0.0.1:1234", header: http.Header{"X-Real-Ip": {"9.9.9.9"}}, ip: "10.0.0.1"},
+		{name: "spoofed x-forwarded-for", remote: "10.0.0.1:1234", ipHeader: "X-Real-IP", header: http.Header{"X-Forwarded-For": {"9.9.9.9"}, "X-Real-Ip": {"5.6.7.8"}}, ip: "5.6.7.8"},
+		{name: "no header", remote: "10.0.0.1:1234", ip: "10.0.0.1"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = c.remote
+			for k, v := range c.header {
+				req.Header[k] = v
+			}
+			ctx := &Context{Request: req, trustedProxies: proxies, clientIPHeader: c.ipHeader}
+			assert.Equal(t, c.ip, ctx.ClientIP())
+		})
+	}
+}
+
+func TestFilterIP(t *testing.T) {
+	filter, err := NewIPFilter([]string{"10.0.0.0/8"}, []string{"10.0.0.13"})
+	assert.Nil(t, err)
+
+	engine := NewEngine()
+	engine.SetConfig(&ServerConfig{TrustedProxies: []string{"127.0.0.1"}})
+	engine.Group("/admin", "admin", func(group *RouteGroup) *RouteGroup {
+		group.GET("/stats", "stats", func(ctx *Context) {
+			ctx.String(http.StatusOK, ctx.ClientIP())
+		})
+		return group
+	}, FilterIP(filter))
+	engine.GET("/public", "ipfilter.public", func(ctx *Context) {
+		ctx.String(http.StatusOK, ctx.ClientIP())
+	})
+
+	request := func(path, xff string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "127.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", xff)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("/admin/stats", "10.1.2.3")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "10.1.2.3", w.Body.String())
+	assert.Equal(t, http.StatusForbidden, request("/admin/stats", "10.0.0.13").Code)
+	assert.Equal(t, http.StatusForbidden, request("/admin/stats", "8.8.8.8").Code)
+	assert.Equal(t, http.StatusOK, request("/public", "8.8.8.8").Code)
+
+	// 运行时更新规则
+	assert.Nil(t, filter.Update(nil, []string{"10.1.0.0/16"}))
+	assert.Equal(t, http.StatusForbidden, request("/admin/stats", "10.1.2.3").Code)
+	assert.Equal(t, http.StatusOK, request("/admin/stats", "8.8.8.8").Code)
+
+	assert.NotNil(t, filter.Update([]string{"bogus"}, nil))
+	assert.False(t, filter.Allowed(net.ParseIP("10.1.2.3")))
+}
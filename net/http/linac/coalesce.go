@@ -0,0 +1,139 @@
+package linac
+
+import (
+	xerror "linac/error"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CoalesceConfig 合并请求中间件配置
+type CoalesceConfig struct {
+	// VaryHeaders 需要区分的请求头，这些请求头不同的请求不会合并
+	VaryHeaders []string
+	// MaxBody 可以共享的响应体最大字节数，默认 1M，超过时等待的请求各自执行 handler
+	MaxBody int
+}
+
+// Coalesce 使用默认配置的合并请求中间件
+func Coalesce() Handler {
+	return CoalesceWithConfig(nil)
+}
+
+// CoalesceWithConfig 合并请求中间件
+// 同时到达的相同 GET 请求 (路由名称、路由参数、query 与 VaryHeaders 相同) 只执行一次 handler，
+// 其他请求等待并共享缓存的响应。等待的请求超时或客户端断开时不再等待，返回 503。
+// 第一个请求 panic、响应为流式、超过 MaxBody 或者设置了 cookie 时，等待的请求各自执行 handler。
+// 携带 Authorization 或 Cookie 的请求响应可能因用户而不同，不会合并
+func CoalesceWithConfig(conf *CoalesceConfig) Handler {
+	if conf == nil {
+		conf = &CoalesceConfig{}
+	}
+	maxBody := conf.MaxBody
+	if maxBody <= 0 {
+		maxBody = _defaultCacheMaxBody
+	}
+	g := &flightGroup{calls: make(map[string]*flightCall)}
+	return func(ctx *Context) {
+		req := ctx.Request
+		if req.Method != http.MethodGet || req.Header.Get(_headerAuthorization) != "" || req.Header.Get("Cookie") != "" {
+			return
+		}
+		key := coalesceKey(ctx, conf.VaryHeaders)
+		call, leader := g.join(key)
+		if !leader {
+			select {
+			case <-call.done:
+			case <-ctx.Done():
+				ctx.Error = ctx.Err()
+				ctx.AbortWithError(http.StatusServiceUnavailable, xerror.ServiceUnavailable)
+				return
+			case <-ctx.Request.Context().Done():
+				// 客户端已经断开，不需要响应
+				ctx.abort = true
+				return
+			}
+			if call.ok {
+				ctx.writeRecorded(call.status, call.header, call.body)
+			}
+			// NOTE: 无法共享响应时继续执行 handler
+			return
+		}
+
+		w := newRecordWriter(ctx.Writer, maxBody)
+		ctx.Writer = w
+		defer func() {
+			ctx.Writer = w.ResponseWriter
+			// NOTE: panic 时 call.ok 为 false，等待的请求各自执行 handler
+			g.finish(key, call)
+		}()
+		ctx.Next()
+		status, header, body, ok := w.recorded()
+		// NOTE: 设置 cookie 的响应属于当前请求，不能共享给其他请求
+		if ok && len(header.Values("Set-Cookie")) == 0 {
+			call.ok, call.status, call.header, call.body = true, status, header, append([]byte(nil), body...)
+		}
+	}
+}
+
+// coalesceKey 以路由名称、路由参数、query 与 VaryHeaders 的值作为合并的 key
+func coalesceKey(ctx *Context, vary []string) string {
+	var b strings.Builder
+	b.WriteString(ctx.RouteName())
+	b.WriteByte('|')
+	names := make([]string, 0, len(ctx.Params))
+	for name := range ctx.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte('=')
+		if v, ok := ctx.Params[name].(string); ok {
+			b.WriteString(v)
+		}
+		b.WriteByte('&')
+	}
+	b.WriteByte('|')
+	b.WriteString(ctx.Request.URL.Query().Encode())
+	for _, h := range vary {
+		b.WriteByte('|')
+		b.WriteString(strings.Join(ctx.Request.Header.Values(h), ","))
+	}
+	return b.String()
+}
+
+// flightGroup 正在执行的请求
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done   chan struct{}
+	ok     bool
+	status int
+	header http.Header
+	body   []byte
+}
+
+// join 加入 key 对应的请求，没有正在执行的请求时成为 leader
+func (g *flightGroup) join(key string) (call *flightCall, leader bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if call, ok := g.calls[key]; ok {
+		return call, false
+	}
+	call = &flightCall{done: make(chan struct{})}
+	g.calls[key] = call
+	return call, true
+}
+
+// finish 结束请求并通知等待的请求
+func (g *flightGroup) finish(key string, call *flightCall) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(call.done)
+}
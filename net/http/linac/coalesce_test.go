@@ -0,0 +1,196 @@
+package linac
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoalesce(t *testing.T) {
+	var (
+		calls   int32
+		release = make(chan struct{})
+	)
+	engine := NewEngine()
+	engine.Use(Coalesce())
+	engine.GET("/items/:id", "coalesce.item", func(ctx *Context) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		ctx.Writer.Header().Set("X-Item", ctx.Params["id"].(string))
+		ctx.String(http.StatusOK, "item %s", ctx.Params["id"])
+	})
+
+	waitInFlight := func(n int32) {
+		for atomic.LoadInt32(&calls) < n {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	t.Run("fan out", func(t *testing.T) {
+		var wg sync.WaitGroup
+		results := make([]*httptest.ResponseRecorder, 10)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				w := httptest.NewRecorder()
+				engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items/1?a=1&b=2", nil))
+				results[i] = w
+			}(i)
+		}
+		// 不同参数的请求不会合并
+		other := make(chan *httptest.ResponseRecorder)
+		go func() {
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items/2", nil))
+			other <- w
+		}()
+		waitInFlight(2)
+		time.Sleep(time.Millisecond * 50)
+		release <- struct{}{}
+		release <- struct{}{}
+		wg.Wait()
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+		for _, w := range results {
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "item 1", w.Body.String())
+			assert.Equal(t, "1", w.Header().Get("X-Item"))
+		}
+		assert.Equal(t, "item 2", (<-other).Body.String())
+	})
+
+	t.Run("waiter cancel", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		leader := make(chan *httptest.ResponseRecorder)
+		go func() {
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items/3", nil))
+			leader <- w
+		}()
+		waitInFlight(1)
+
+		c, cancel := context.WithCancel(context.Background())
+		waiter := make(chan *httptest.ResponseRecorder)
+		go func() {
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items/3", nil).WithContext(c))
+			waiter <- w
+		}()
+		time.Sleep(time.Millisecond * 50)
+		cancel()
+		select {
+		case <-waiter:
+		case <-time.After(time.Second):
+			t.Fatal("waiter should return after cancel")
+		}
+		close(release)
+		assert.Equal(t, "item 3", (<-leader).Body.String())
+	})
+}
+
+func TestCoalesceOuterHeaders(t *testing.T) {
+	var (
+		calls   int32
+		release = make(chan struct{})
+	)
+	engine := NewEngine()
+	engine.Use(Trace(), Coalesce())
+	engine.GET("/trace", "coalesce.trace", func(ctx *Context) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		ctx.Writer.Header().Set("X-Handler", "1")
+		ctx.String(http.StatusOK, "ok")
+	})
+	serve := func(requestID string) <-chan *httptest.ResponseRecorder {
+		ch := make(chan *httptest.ResponseRecorder, 1)
+		go func() {
+			req := httptest.NewRequest(http.MethodGet, "/trace", nil)
+			req.Header.Set(_headerRequestID, requestID)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			ch <- w
+		}()
+		return ch
+	}
+
+	leader := serve("req-1")
+	for atomic.LoadInt32(&calls) < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	waiter := serve("req-2")
+	time.Sleep(time.Millisecond * 50)
+	release <- struct{}{}
+	assert.Equal(t, "req-1", (<-leader).Header().Get(_headerRequestID))
+	w := <-waiter
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, "req-2", w.Header().Get(_headerRequestID))
+	assert.Equal(t, "1", w.Header().Get("X-Handler"))
+}
+
+func TestCoalescePrivate(t *testing.T) {
+	var (
+		calls   int32
+		release = make(chan struct{})
+	)
+	engine := NewEngine()
+	engine.Use(Coalesce())
+	engine.GET("/me", "coalesce.me", func(ctx *Context) {
+		n := atomic.AddInt32(&calls, 1)
+		<-release
+		if ctx.Request.URL.Query().Get("cookie") != "" {
+			http.SetCookie(ctx.Writer, &http.Cookie{Name: "session", Value: strconv.Itoa(int(n))})
+		}
+		ctx.String(http.StatusOK, "%s", ctx.Request.Header.Get("Authorization"))
+	})
+
+	serve := func(target string, header http.Header) <-chan *httptest.ResponseRecorder {
+		ch := make(chan *httptest.ResponseRecorder, 1)
+		go func() {
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			for k, v := range header {
+				req.Header[k] = v
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			ch <- w
+		}()
+		return ch
+	}
+
+	t.Run("different users", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		alice := serve("/me", http.Header{"Authorization": {"Bearer alice"}})
+		bob := serve("/me", http.Header{"Authorization": {"Bearer bob"}, "Cookie": {"session=bob"}})
+		for atomic.LoadInt32(&calls) < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		release <- struct{}{}
+		release <- struct{}{}
+		assert.Equal(t, "Bearer alice", (<-alice).Body.String())
+		assert.Equal(t, "Bearer bob", (<-bob).Body.String())
+	})
+
+	t.Run("set cookie is not shared", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		leader := serve("/me?cookie=1", nil)
+		for atomic.LoadInt32(&calls) < 1 {
+			time.Sleep(time.Millisecond)
+		}
+		waiter := serve("/me?cookie=1", nil)
+		time.Sleep(time.Millisecond * 50)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+		release <- struct{}{}
+		assert.Equal(t, "session=1", (<-leader).Header().Get("Set-Cookie"))
+		// 等待的请求自己执行 handler
+		release <- struct{}{}
+		assert.Equal(t, "session=2", (<-waiter).Header().Get("Set-Cookie"))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+}
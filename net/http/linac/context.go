@@ -1,11 +1,15 @@
 package linac
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io/ioutil"
 	xerror "linac/error"
 	"linac/net/http/linac/render"
+	"net"
 	"net/http"
+	"strings"
 )
 
 // Context http 请求上下文
@@ -20,6 +24,14 @@ type Context struct {
 
 	abort bool
 	index int
+	route *Route
+
+	maxRequestBody     int64
+	maxMultipartMemory int64
+	multipartHash      string
+	trustedProxies     []*net.IPNet
+	clientIPHeader     string
+	closing            <-chan struct{}
 }
 
 // Get 获取GET请求参数
@@ -56,6 +68,39 @@ func (ctx *Context) Input(name string) (value interface{}) {
 	return
 }
 
+// parseBody 解析表单请求体
+// 压缩过的请求体无法直接解析，交由解压中间件解压后再解析
+func (ctx *Context) parseBody() error {
+	req := ctx.Request
+	if enc := req.Header.Get("Content-Encoding"); enc != "" && !strings.EqualFold(enc, "identity") {
+		return nil
+	}
+	ctype := req.Header.Get("Content-Type")
+	switch {
+	case strings.Contains(ctype, "multipart/form-data"):
+		return ctx.parseMultipartForm()
+	case strings.Contains(ctype, "application/x-www-form-urlencoded"):
+		// 保留原始请求体，解析后中间件 (如签名校验) 仍可读取
+		body, err := ctx.readBody()
+		if err != nil {
+			return err
+		}
+		err = req.ParseForm()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		return err
+	default:
+		return req.ParseForm()
+	}
+}
+
+// RouteName 返回匹配到的路由名称，未匹配到路由时返回空字符串
+func (ctx *Context) RouteName() string {
+	if ctx.route == nil {
+		return ""
+	}
+	return ctx.route.name
+}
+
 // Next 继续执行下一个handler
 // Note: 此方法应该只在中间件中调用
 func (ctx *Context) Next() {
@@ -74,14 +119,25 @@ func (ctx *Context) Abort(code int) {
 	ctx.abort = true
 }
 
+// AbortWithError 以 JSON 格式输出错误码，并停止继续使用handlers处理ctx
+func (ctx *Context) AbortWithError(code int, err error) {
+	ctx.Error = err
+	bErr := xerror.Cause(err)
+	ctx.render(render.JSON{
+		Code: bErr.Code(),
+		Err:  bErr.Message(),
+	}, code)
+	ctx.abort = true
+}
+
 // IsAbort 返回context是否终止响应
 func (ctx *Context) IsAbort() bool {
 	return ctx.abort
 }
 
 func (ctx *Context) render(r render.IRender, code int) {
-	ctx.Writer.WriteHeader(code)
 	ctx.writeContentType(r.ContentType())
+	ctx.Writer.WriteHeader(code)
 	if err := render.Write(r, ctx.Writer); err != nil {
 		ctx.Error = err
 	}
@@ -109,6 +165,29 @@ func (ctx *Context) JSONMap(data map[string]interface{}, err error) {
 	ctx.render(render.JSONMap(data), http.StatusOK)
 }
 
+// MsgPack 将数据 msgpack 编码到response中
+// 设置 content type 为 application/msgpack
+func (ctx *Context) MsgPack(data interface{}, err error) {
+	ctx.Error = err
+	bErr := xerror.Cause(err)
+	ctx.render(render.MsgPack{
+		Code: bErr.Code(),
+		Data: data,
+		Err:  bErr.Message(),
+	}, http.StatusOK)
+}
+
+// Negotiate 根据请求的 Accept 选择 JSON 或 MsgPack 编码数据
+// 默认使用 JSON
+func (ctx *Context) Negotiate(data interface{}, err error) {
+	switch negotiate(ctx.Request.Header.Get("Accept"), _mimeJSON, _mimeMsgPack) {
+	case _mimeMsgPack:
+		ctx.MsgPack(data, err)
+	default:
+		ctx.JSON(data, err)
+	}
+}
+
 // String 将字符串写入response body中
 // 设置 content type 为 text/plain; charset=utf-8
 func (ctx *Context) String(code int, sfmt string, value ...interface{}) {
@@ -117,6 +196,18 @@ func (ctx *Context) String(code int, sfmt string, value ...interface{}) {
 	}, code)
 }
 
+// NDJSON 将行数据逐行编码为 json 流式写入 response 中
+// 设置 content type 为 application/x-ndjson; charset=utf-8
+func (ctx *Context) NDJSON(code int, r render.NDJSON) {
+	ctx.render(r, code)
+}
+
+// CSV 将记录编码为 csv 流式写入 response 中
+// 设置 content type 为 text/csv; charset=utf-8
+func (ctx *Context) CSV(code int, r render.CSV) {
+	ctx.render(r, code)
+}
+
 func (ctx *Context) writeContentType(ctype string) {
 	header := ctx.Writer.Header()
 	header.Set("Content-Type", ctype)
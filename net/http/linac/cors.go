@@ -0,0 +1,181 @@
+package linac
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	_defaultCORSMethods = []string{"GET", "POST", "PUT", "DELETE", "HEAD"}
+	_defaultCORSHeaders = []string{"Origin", "Accept", "Content-Type", "X-Requested-With"}
+
+	// _defaultCORSConfig 允许所有来源，不允许携带凭证
+	_defaultCORSConfig = &CORSConfig{AllowOrigins: []string{"*"}}
+)
+
+// CORSConfig 跨域资源共享配置
+type CORSConfig struct {
+	// AllowOrigins 允许的来源，支持精确匹配 "https://example.com"、
+	// 子域名通配 "https://*.example.com" 以及 "*" 匹配所有来源
+	AllowOrigins []string
+	// AllowOriginFunc 自定义来源检查，AllowOrigins 未匹配时调用
+	AllowOriginFunc func(origin string) bool
+	// AllowMethods 允许的请求方法，为空时使用 GET、POST、PUT、DELETE、HEAD
+	AllowMethods []string
+	// AllowHeaders 允许的请求头，"*" 允许所有请求头，为空时使用默认的常用请求头
+	AllowHeaders []string
+	// ExposeHeaders 允许浏览器读取的响应头
+	ExposeHeaders []string
+	// AllowCredentials 是否允许携带 cookie 等凭证
+	// NOTE: 此时不会返回 "Access-Control-Allow-Origin: *"，而是返回请求的来源
+	AllowCredentials bool
+	// MaxAge 预检请求结果的缓存时间
+	MaxAge time.Duration
+}
+
+// CORS 跨域资源共享中间件
+// 直接响应预检请求 (OPTIONS)，即使没有注册对应的 OPTIONS 路由。
+// conf 为 nil 时允许所有来源的请求。
+// NOTE: 未匹配路由的请求只会执行 Engine 上的中间件，处理预检请求时应通过 engine.Use 注册
+func CORS(conf *CORSConfig) Handler {
+	if conf == nil {
+		conf = _defaultCORSConfig
+	}
+	c := &cors{
+		conf:    conf,
+		methods: conf.AllowMethods,
+		headers: make(map[string]struct{}),
+	}
+	if len(c.methods) == 0 {
+		c.methods = _defaultCORSMethods
+	}
+	headers := conf.AllowHeaders
+	if len(headers) == 0 {
+		headers = _defaultCORSHeaders
+	}
+	for _, h := range headers {
+		if h == "*" {
+			c.anyHeader = true
+		}
+		c.headers[strings.ToLower(h)] = struct{}{}
+	}
+	for _, o := range conf.AllowOrigins {
+		if o == "*" {
+			c.anyOrigin = true
+		}
+	}
+	return c.handle
+}
+
+type cors struct {
+	conf      *CORSConfig
+	methods   []string
+	headers   map[string]struct{}
+	anyOrigin bool
+	anyHeader bool
+}
+
+func (c *cors) handle(ctx *Context) {
+	req := ctx.Request
+	header := ctx.Writer.Header()
+	origin := req.Header.Get("Origin")
+	preflight := req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != ""
+	if origin == "" {
+		return
+	}
+	addVary(header, "Origin")
+	if preflight {
+		addVary(header, "Access-Control-Request-Method")
+		addVary(header, "Access-Control-Request-Headers")
+	}
+	if !c.allowOrigin(origin) {
+		if preflight {
+			ctx.Abort(http.StatusForbidden)
+		}
+		return
+	}
+	if c.anyOrigin && !c.conf.AllowCredentials {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.conf.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if !preflight {
+		if len(c.conf.ExposeHeaders) > 0 {
+			header.Set("Access-Control-Expose-Headers", strings.Join(c.conf.ExposeHeaders, ", "))
+		}
+		return
+	}
+
+	method := req.Header.Get("Access-Control-Request-Method")
+	requested := parseHeaderList(req.Header.Get("Access-Control-Request-Headers"))
+	if !c.allowMethod(method) || !c.allowHeaders(requested) {
+		ctx.Abort(http.StatusForbidden)
+		return
+	}
+	header.Set("Access-Control-Allow-Methods", strings.Join(c.methods, ", "))
+	if len(requested) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+	}
+	if c.conf.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.FormatInt(int64(c.conf.MaxAge/time.Second), 10))
+	}
+	ctx.Abort(http.StatusNoContent)
+}
+
+func (c *cors) allowOrigin(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+	for _, o := range c.conf.AllowOrigins {
+		if strings.EqualFold(o, origin) {
+			return true
+		}
+		if i := strings.Index(o, "*."); i != -1 {
+			prefix, suffix := o[:i], o[i+1:]
+			if len(origin) > len(prefix)+len(suffix) &&
+				strings.HasPrefix(strings.ToLower(origin), strings.ToLower(prefix)) &&
+				strings.HasSuffix(strings.ToLower(origin), strings.ToLower(suffix)) {
+				return true
+			}
+		}
+	}
+	if c.conf.AllowOriginFunc != nil {
+		return c.conf.AllowOriginFunc(origin)
+	}
+	return false
+}
+
+func (c *cors) allowMethod(method string) bool {
+	for _, m := range c.methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *cors) allowHeaders(headers []string) bool {
+	if c.anyHeader {
+		return true
+	}
+	for _, h := range headers {
+		if _, ok := c.headers[strings.ToLower(h)]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func parseHeaderList(list string) (headers []string) {
+	for _, h := range strings.Split(list, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			headers = append(headers, h)
+		}
+	}
+	return
+}
@@ -0,0 +1,103 @@
+package linac
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORS(t *testing.T) {
+	engine := NewEngine()
+	engine.Use(CORS(&CORSConfig{
+		AllowOrigins:     []string{"https://example.com", "https://*.linac.io"},
+		AllowOriginFunc:  func(origin string) bool { return strings.HasSuffix(origin, ".local") },
+		AllowHeaders:     []string{"Content-Type", "Authorization"},
+		ExposeHeaders:    []string{"X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	}))
+	engine.POST("/users", "cors.users", func(ctx *Context) {
+		ctx.String(200, "ok")
+	})
+
+	do := func(method string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/users", nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Should answer preflight without OPTIONS route", func(t *testing.T) {
+		w := do("OPTIONS", map[string]string{
+			"Origin":                         "https://api.linac.io",
+			"Access-Control-Request-Method":  "POST",
+			"Access-Control-Request-Headers": "content-type, authorization",
+		})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "https://api.linac.io", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Equal(t, "content-type, authorization", w.Header().Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "GET, POST, PUT, DELETE, HEAD", w.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "3600", w.Header().Get("Access-Control-Max-Age"))
+		assert.Equal(t, []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}, w.Header().Values("Vary"))
+	})
+
+	t.Run("Should reject disallowed preflight", func(t *testing.T) {
+		w := do("OPTIONS", map[string]string{"Origin": "https://evil.com", "Access-Control-Request-Method": "POST"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, "", w.Header().Get("Access-Control-Allow-Origin"))
+
+		w = do("OPTIONS", map[string]string{"Origin": "https://example.com", "Access-Control-Request-Method": "PATCH"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = do("OPTIONS", map[string]string{
+			"Origin":                         "https://example.com",
+			"Access-Control-Request-Method":  "POST",
+			"Access-Control-Request-Headers": "X-Custom",
+		})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Should set headers on actual request", func(t *testing.T) {
+		w := do("POST", map[string]string{"Origin": "http://dev.local"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "http://dev.local", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "X-Request-ID", w.Header().Get("Access-Control-Expose-Headers"))
+		assert.Equal(t, "Origin", w.Header().Get("Vary"))
+
+		w = do("POST", map[string]string{"Origin": "https://linac.io"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "", w.Header().Get("Access-Control-Allow-Origin"))
+
+		w = do("POST", nil)
+		assert.Equal(t, "", w.Header().Get("Vary"))
+	})
+
+	t.Run("Should still 404 for unknown route", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/unknown", nil)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestCORSDefaultConfig(t *testing.T) {
+	engine := NewEngine()
+	engine.Use(CORS(nil))
+	engine.GET("/public", "cors.public", func(ctx *Context) {
+		ctx.String(200, "ok")
+	})
+	req := httptest.NewRequest("GET", "/public", nil)
+	req.Header.Set("Origin", "https://any.example")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,271 @@
+package linac
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	xerror "linac/error"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	_csrfTokenLen = 32
+)
+
+var (
+	_defaultCSRFConfig = &CSRFConfig{
+		CookieName:     "_csrf",
+		CookiePath:     "/",
+		CookieSameSite: http.SameSiteLaxMode,
+		MaxAge:         time.Hour * time.Duration(12),
+		Header:         "X-CSRF-Token",
+		FormField:      "_csrf",
+	}
+)
+
+type csrfKey struct{}
+
+// CSRFConfig 跨站请求伪造防护配置
+type CSRFConfig struct {
+	// CookieName 保存令牌的 cookie，默认为 _csrf
+	CookieName string
+	// CookiePath cookie 路径，默认为 /
+	CookiePath     string
+	CookieDomain   string
+	CookieSecure   bool
+	CookieHTTPOnly bool
+	// CookieSameSite 默认为 Lax
+	CookieSameSite http.SameSite
+	// MaxAge 令牌的有效期，默认 12 小时
+	MaxAge time.Duration
+
+	// Header 提交令牌的请求头，默认为 X-CSRF-Token
+	Header string
+	// FormField 请求头中没有令牌时读取的表单字段，默认为 _csrf
+	FormField string
+
+	// ExemptRoutes 不检查令牌的路由名称，如接收第三方回调的路由
+	ExemptRoutes []string
+	// TrustedOrigins 除本站外允许的来源，如 "https://admin.example.com"
+	TrustedOrigins []string
+
+	// Store 同步令牌模式下保存会话的令牌，为 nil 时使用 double submit cookie 模式
+	Store CSRFStore
+	// Session 同步令牌模式下返回请求的会话 ID，返回空字符串时退化为 double submit cookie 模式
+	Session func(*Context) string
+}
+
+// CSRFStore 同步令牌模式下的令牌存储
+type CSRFStore interface {
+	// Get 返回会话的令牌
+	Get(session string) (token string, ok bool)
+	// Set 保存会话的令牌，ttl 后过期
+	Set(session, token string, ttl time.Duration)
+}
+
+// CSRF 使用默认配置的跨站请求伪造防护中间件
+func CSRF() Handler {
+	return CSRFWithConfig(nil)
+}
+
+// CSRFWithConfig 跨站请求伪造防护中间件
+// GET、HEAD、OPTIONS、TRACE 请求及豁免的路由只下发令牌，其他请求需要检查 Origin/Referer，
+// 并通过请求头或表单字段提交与 cookie (或会话) 中一致的令牌，否则返回 403。
+// 模板中可以通过 ctx.CSRFToken() 获取令牌
+func CSRFWithConfig(conf *CSRFConfig) Handler {
+	c := &csrf{conf: _defaultCSRFConfig, exempt: make(map[string]struct{})}
+	if conf != nil {
+		merged := *conf
+		if merged.CookieName == "" {
+			merged.CookieName = _defaultCSRFConfig.CookieName
+		}
+		if merged.CookiePath == "" {
+			merged.CookiePath = _defaultCSRFConfig.CookiePath
+		}
+		if merged.CookieSameSite == 0 {
+			merged.CookieSameSite = _defaultCSRFConfig.CookieSameSite
+		}
+		if merged.MaxAge <= 0 {
+			merged.MaxAge = _defaultCSRFConfig.MaxAge
+		}
+		if merged.Header == "" {
+			merged.Header = _defaultCSRFConfig.Header
+		}
+		if merged.FormField == "" {
+			merged.FormField = _defaultCSRFConfig.FormField
+		}
+		c.conf = &merged
+	}
+	for _, name := range c.conf.ExemptRoutes {
+		c.exempt[name] = struct{}{}
+	}
+	return c.handle
+}
+
+type csrf struct {
+	conf   *CSRFConfig
+	exempt map[string]struct{}
+}
+
+func (c *csrf) handle(ctx *Context) {
+	session := ""
+	if c.conf.Store != nil && c.conf.Session != nil {
+		session = c.conf.Session(ctx)
+	}
+	token, ok := c.token(ctx, session)
+	if !ok {
+		token = newCSRFToken()
+		c.save(ctx, session, token)
+	}
+	ctx.Context = context.WithValue(ctx.Context, csrfKey{}, token)
+
+	switch ctx.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return
+	}
+	if _, ok := c.exempt[ctx.RouteName()]; ok {
+		return
+	}
+	if !ok || !c.checkOrigin(ctx.Request) {
+		ctx.AbortWithError(http.StatusForbidden, xerror.Forbidden)
+		return
+	}
+	submitted := ctx.Request.Header.Get(c.conf.Header)
+	if submitted == "" && ctx.Request.PostForm != nil {
+		submitted = ctx.Request.PostForm.Get(c.conf.FormField)
+	}
+	if subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) != 1 {
+		ctx.AbortWithError(http.StatusForbidden, xerror.Forbidden)
+	}
+}
+
+// token 返回 cookie 或会话中的令牌
+func (c *csrf) token(ctx *Context, session string) (string, bool) {
+	if session != "" {
+		token, ok := c.conf.Store.Get(session)
+		return token, ok && token != ""
+	}
+	cookie, err := ctx.Request.Cookie(c.conf.CookieName)
+	if err != nil || len(cookie.Value) != base64.RawURLEncoding.EncodedLen(_csrfTokenLen) {
+		return "", false
+	}
+	return cookie.Value, true
+}
+
+func (c *csrf) save(ctx *Context, session, token string) {
+	if session != "" {
+		c.conf.Store.Set(session, token, c.conf.MaxAge)
+		return
+	}
+	http.SetCookie(ctx.Writer, &http.Cookie{
+		Name:     c.conf.CookieName,
+		Value:    token,
+		Path:     c.conf.CookiePath,
+		Domain:   c.conf.CookieDomain,
+		Expires:  time.Now().Add(c.conf.MaxAge),
+		MaxAge:   int(c.conf.MaxAge / time.Second),
+		Secure:   c.conf.CookieSecure,
+		HttpOnly: c.conf.CookieHTTPOnly,
+		SameSite: c.conf.CookieSameSite,
+	})
+	addVary(ctx.Writer.Header(), "Cookie")
+}
+
+// checkOrigin 检查 Origin，没有 Origin 时检查 Referer，都没有时只检查令牌
+func (c *csrf) checkOrigin(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" || origin == "null" {
+		referer := req.Header.Get("Referer")
+		if referer == "" {
+			return origin == ""
+		}
+		u, err := url.Parse(referer)
+		if err != nil || u.Host == "" {
+			return false
+		}
+		origin = u.Scheme + "://" + u.Host
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, req.Host) {
+		return true
+	}
+	for _, trusted := range c.conf.TrustedOrigins {
+		if strings.EqualFold(trusted, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// CSRFToken 返回当前请求的 CSRF 令牌，用于渲染到表单或页面中，未使用 CSRF 中间件时返回空字符串
+func (ctx *Context) CSRFToken() string {
+	if ctx.Context == nil {
+		return ""
+	}
+	token, _ := ctx.Value(csrfKey{}).(string)
+	return token
+}
+
+func newCSRFToken() string {
+	b := make([]byte, _csrfTokenLen)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// MemoryCSRFStore 内存令牌存储，定期清理过期的令牌
+type MemoryCSRFStore struct {
+	mu        sync.Mutex
+	tokens    map[string]csrfEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type csrfEntry struct {
+	token  string
+	expire time.Time
+}
+
+// NewMemoryCSRFStore 返回内存令牌存储
+func NewMemoryCSRFStore() *MemoryCSRFStore {
+	return &MemoryCSRFStore{
+		tokens: make(map[string]csrfEntry),
+		now:    time.Now,
+	}
+}
+
+// Get 返回会话的令牌
+func (s *MemoryCSRFStore) Get(session string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.tokens[session]
+	if !ok || s.now().After(e.expire) {
+		return "", false
+	}
+	return e.token, true
+}
+
+// Set 保存会话的令牌
+func (s *MemoryCSRFStore) Set(session, token string, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.lastSweep) >= _sweepInterval {
+		for k, e := range s.tokens {
+			if now.After(e.expire) {
+				delete(s.tokens, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	s.tokens[session] = csrfEntry{token: token, expire: now.Add(ttl)}
+}
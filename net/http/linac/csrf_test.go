@@ -0,0 +1,123 @@
+package linac
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSRF(t *testing.T) {
+	engine := NewEngine()
+	engine.Use(CSRFWithConfig(&CSRFConfig{
+		ExemptRoutes:   []string{"csrf.callback"},
+		TrustedOrigins: []string{"https://admin.example.com"},
+	}))
+	engine.GET("/form", "csrf.form", func(ctx *Context) {
+		ctx.String(http.StatusOK, ctx.CSRFToken())
+	})
+	engine.POST("/form", "csrf.submit", func(ctx *Context) {
+		ctx.String(http.StatusOK, "ok")
+	})
+	engine.POST("/callback", "csrf.callback", func(ctx *Context) {
+		ctx.String(http.StatusOK, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://example.com/form", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	token := w.Body.String()
+	cookies := w.Result().Cookies()
+	assert.Len(t, cookies, 1)
+	assert.Equal(t, "_csrf", cookies[0].Name)
+	assert.Equal(t, token, cookies[0].Value)
+
+	post := func(header http.Header, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/form", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(cookies[0])
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("header", func(t *testing.T) {
+		w := post(http.Header{"X-Csrf-Token": {token}, "Origin": {"http://example.com"}}, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("form field", func(t *testing.T) {
+		w := post(http.Header{"Referer": {"https://admin.example.com/page"}}, url.Values{"_csrf": {token}})
+		assert.Equal(t, http.StatusOK, w.Code)
+		// 令牌保持不变
+		assert.Empty(t, w.Result().Cookies())
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		w := post(nil, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":403`)
+	})
+
+	t.Run("wrong token", func(t *testing.T) {
+		w := post(http.Header{"X-Csrf-Token": {"x" + token[1:]}}, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("cross origin", func(t *testing.T) {
+		w := post(http.Header{"X-Csrf-Token": {token}, "Origin": {"https://evil.com"}}, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("exempt route", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "http://example.com/callback", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestCSRFSynchronizer(t *testing.T) {
+	store := NewMemoryCSRFStore()
+	engine := NewEngine()
+	engine.Use(CSRFWithConfig(&CSRFConfig{
+		Store: store,
+		Session: func(ctx *Context) string {
+			return ctx.Request.Header.Get("X-Session")
+		},
+	}))
+	engine.GET("/form", "csrf.sync.form", func(ctx *Context) {
+		ctx.String(http.StatusOK, ctx.CSRFToken())
+	})
+	engine.POST("/form", "csrf.sync.submit", func(ctx *Context) {
+		ctx.String(http.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/form", nil)
+	req.Header.Set("X-Session", "s1")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	token := w.Body.String()
+	assert.Empty(t, w.Result().Cookies())
+	stored, ok := store.Get("s1")
+	assert.True(t, ok)
+	assert.Equal(t, token, stored)
+
+	req = httptest.NewRequest(http.MethodPost, "/form", nil)
+	req.Header.Set("X-Session", "s1")
+	req.Header.Set("X-CSRF-Token", token)
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// 其他会话不能使用该令牌
+	req.Header.Set("X-Session", "s2")
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
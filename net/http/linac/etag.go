@@ -0,0 +1,181 @@
+package linac
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETag 条件请求中间件
+// 为 GET/HEAD 请求的 2xx 响应生成 ETag (handler 已通过 SetETag 设置时不再生成)，
+// 并根据 If-Match、If-Unmodified-Since、If-None-Match、If-Modified-Since 返回 304 或 412
+// NOTE: 响应经过压缩等转换时应使用弱 ETag
+func ETag(weak bool) Handler {
+	return func(ctx *Context) {
+		method := ctx.Request.Method
+		if method != http.MethodGet && method != http.MethodHead {
+			return
+		}
+		w := newBufferWriter(ctx.Writer)
+		ctx.Writer = w
+		defer func() {
+			ctx.Writer = w.ResponseWriter
+		}()
+		ctx.Next()
+		if w.streamed {
+			return
+		}
+		header := w.Header()
+		if w.status >= http.StatusOK && w.status < http.StatusMultipleChoices {
+			if header.Get("ETag") == "" && w.body.Len() > 0 {
+				header.Set("ETag", generateETag(w.body.Bytes(), weak))
+			}
+			if code := checkPreconditions(ctx.Request, header); code != 0 {
+				writePrecondition(w.ResponseWriter, code)
+				return
+			}
+		}
+		w.flush()
+	}
+}
+
+// SetETag 设置响应的 ETag，tag 不需要包含引号
+func (ctx *Context) SetETag(tag string, weak bool) {
+	tag = `"` + tag + `"`
+	if weak {
+		tag = "W/" + tag
+	}
+	ctx.Writer.Header().Set("ETag", tag)
+}
+
+// SetLastModified 设置响应的 Last-Modified
+func (ctx *Context) SetLastModified(t time.Time) {
+	ctx.Writer.Header().Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+}
+
+// CheckPreconditions 根据已设置的 ETag 和 Last-Modified 检查条件请求
+// 条件不满足时以 304 或 412 终止响应并返回 false。
+// 非 GET/HEAD 请求应在修改资源之前调用，如 PUT 请求的 If-Match
+func (ctx *Context) CheckPreconditions() bool {
+	code := checkPreconditions(ctx.Request, ctx.Writer.Header())
+	if code == 0 {
+		return true
+	}
+	writePrecondition(ctx.Writer, code)
+	ctx.abort = true
+	return false
+}
+
+func generateETag(body []byte, weak bool) string {
+	sum := sha1.Sum(body)
+	tag := `"` + base64.RawURLEncoding.EncodeToString(sum[:]) + `"`
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// checkPreconditions 按 RFC 7232 第 6 节的顺序检查条件请求
+// 返回 0 表示条件满足，否则返回 304 或 412
+func checkPreconditions(req *http.Request, header http.Header) int {
+	etag := header.Get("ETag")
+	lastModified, lmErr := http.ParseTime(header.Get("Last-Modified"))
+	safe := req.Method == http.MethodGet || req.Method == http.MethodHead
+
+	if im := req.Header.Get("If-Match"); im != "" {
+		if !matchETag(im, etag, false) {
+			return http.StatusPreconditionFailed
+		}
+	} else if ius := req.Header.Get("If-Unmodified-Since"); ius != "" && lmErr == nil {
+		if t, err := http.ParseTime(ius); err == nil && lastModified.Truncate(time.Second).After(t) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		if matchETag(inm, etag, true) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if ims := req.Header.Get("If-Modified-Since"); ims != "" && safe && lmErr == nil {
+		if t, err := http.ParseTime(ims); err == nil && !lastModified.Truncate(time.Second).After(t) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+// writePrecondition 写入 304 或 412 响应
+func writePrecondition(w http.ResponseWriter, code int) {
+	header := w.Header()
+	if code == http.StatusNotModified {
+		// 304 响应不能包含响应体的相关头
+		header.Del("Content-Type")
+		header.Del("Content-Length")
+		if header.Get("ETag") != "" {
+			header.Del("Last-Modified")
+		}
+	}
+	w.WriteHeader(code)
+}
+
+// matchETag 检查条件头中的 ETag 列表是否与 etag 匹配
+// weak 为 true 时使用弱比较 (If-None-Match)，否则使用强比较 (If-Match)。
+// 以是否设置了 ETag 判断资源是否存在，"*" 匹配任意存在的资源
+func matchETag(list, etag string, weak bool) bool {
+	list = strings.TrimSpace(list)
+	if list == "*" {
+		return etag != ""
+	}
+	if etag == "" {
+		return false
+	}
+	for {
+		list = strings.TrimLeft(list, " \t,")
+		if list == "" {
+			return false
+		}
+		tag, rest := scanETag(list)
+		if tag == "" {
+			return false
+		}
+		if weak && weakEqual(tag, etag) || !weak && strongEqual(tag, etag) {
+			return true
+		}
+		list = rest
+	}
+}
+
+// scanETag 读取 s 开头的一个 ETag，返回 ETag 和剩余部分
+func scanETag(s string) (tag, rest string) {
+	start := 0
+	if strings.HasPrefix(s, "W/") {
+		start = 2
+	}
+	if len(s)-start < 2 || s[start] != '"' {
+		return "", ""
+	}
+	for i := start + 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			return s[:i+1], s[i+1:]
+		case c == 0x21 || c >= 0x23 && c <= 0x7e || c >= 0x80:
+		default:
+			return "", ""
+		}
+	}
+	return "", ""
+}
+
+func strongEqual(a, b string) bool {
+	return a == b && a != "" && !strings.HasPrefix(a, "W/")
+}
+
+func weakEqual(a, b string) bool {
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
@@ -0,0 +1,86 @@
+package linac
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestETag(t *testing.T) {
+	modified := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	engine := NewEngine()
+	engine.Use(ETag(false))
+	engine.GET("/auto", "etag.auto", func(ctx *Context) {
+		ctx.String(200, "config")
+	})
+	engine.GET("/manual", "etag.manual", func(ctx *Context) {
+		ctx.SetETag("v1", true)
+		ctx.SetLastModified(modified)
+		ctx.String(200, "list")
+	})
+	engine.PUT("/manual", "etag.update", func(ctx *Context) {
+		ctx.SetETag("v1", false)
+		if !ctx.CheckPreconditions() {
+			return
+		}
+		ctx.String(200, "updated")
+	})
+
+	do := func(method, path string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Should generate strong etag and return 304", func(t *testing.T) {
+		w := do("GET", "/auto", nil)
+		etag := w.Header().Get("ETag")
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "config", w.Body.String())
+		assert.Equal(t, generateETag([]byte("config"), false), etag)
+
+		w = do("GET", "/auto", map[string]string{"If-None-Match": `"other", ` + etag})
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Equal(t, "", w.Body.String())
+		assert.Equal(t, "", w.Header().Get("Content-Type"))
+		assert.Equal(t, etag, w.Header().Get("ETag"))
+
+		w = do("GET", "/auto", map[string]string{"If-Match": `"other"`})
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+
+	t.Run("Should use etag and last modified set by handler", func(t *testing.T) {
+		w := do("GET", "/manual", map[string]string{"If-None-Match": `"v1"`})
+		assert.Equal(t, http.StatusNotModified, w.Code)
+
+		w = do("GET", "/manual", map[string]string{"If-Match": `W/"v1"`})
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = do("GET", "/manual", map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)})
+		assert.Equal(t, http.StatusNotModified, w.Code)
+
+		w = do("GET", "/manual", map[string]string{"If-Modified-Since": modified.Add(-time.Hour).Format(http.TimeFormat)})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "list", w.Body.String())
+
+		w = do("GET", "/manual", map[string]string{"If-Unmodified-Since": modified.Add(-time.Hour).Format(http.TimeFormat)})
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+
+	t.Run("Should check preconditions in handler", func(t *testing.T) {
+		w := do("PUT", "/manual", map[string]string{"If-Match": `"v0"`})
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+		assert.Equal(t, "", w.Body.String())
+
+		w = do("PUT", "/manual", map[string]string{"If-Match": `"v1"`})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "updated", w.Body.String())
+	})
+}
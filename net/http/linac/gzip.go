@@ -0,0 +1,360 @@
+package linac
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	xerror "linac/error"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	_encodingGzip    = "gzip"
+	_encodingDeflate = "deflate"
+)
+
+var (
+	_defaultGzipConfig = &GzipConfig{
+		Level:   gzip.DefaultCompression,
+		MinSize: 1 << 10, // 1K
+		ContentTypes: []string{
+			"text/",
+			"application/json",
+			"application/x-ndjson",
+			"application/javascript",
+			"application/xml",
+			"application/msgpack",
+			"image/svg+xml",
+		},
+	}
+
+	// 已经压缩过的类型，即使在 ContentTypes 中也不会再压缩
+	_compressedTypes = []string{
+		"image/png",
+		"image/jpeg",
+		"image/gif",
+		"image/webp",
+		"video/",
+		"audio/",
+		"font/woff",
+		"application/zip",
+		"application/gzip",
+		"application/x-gzip",
+		"application/x-7z-compressed",
+		"application/x-rar-compressed",
+	}
+)
+
+// GzipConfig 响应压缩配置
+type GzipConfig struct {
+	// Level 压缩等级，同 compress/gzip
+	Level int
+	// MinSize 响应体小于该大小时不压缩
+	MinSize int
+	// ContentTypes 允许压缩的 content type 前缀
+	ContentTypes []string
+	// DecompressRequest 是否解压 Content-Encoding 为 gzip 的请求体
+	DecompressRequest bool
+}
+
+// compressor gzip.Writer 和 zlib.Writer 的公共接口
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// Gzip 使用默认配置的响应压缩中间件
+func Gzip() Handler {
+	return GzipWithConfig(_defaultGzipConfig)
+}
+
+// GzipWithConfig 响应压缩中间件
+// 根据 Accept-Encoding 协商使用 gzip 或 deflate 压缩响应体，
+// 不压缩 Range 请求、已设置 Content-Encoding 的响应以及小于 MinSize 的响应
+func GzipWithConfig(conf *GzipConfig) Handler {
+	if conf == nil {
+		conf = _defaultGzipConfig
+	}
+	if _, err := gzip.NewWriterLevel(nil, conf.Level); err != nil {
+		panic(err)
+	}
+	pools := map[string]*sync.Pool{
+		_encodingGzip: {New: func() interface{} {
+			w, _ := gzip.NewWriterLevel(nil, conf.Level)
+			return w
+		}},
+		_encodingDeflate: {New: func() interface{} {
+			w, _ := zlib.NewWriterLevel(nil, conf.Level)
+			return w
+		}},
+	}
+	return func(ctx *Context) {
+		req := ctx.Request
+		if conf.DecompressRequest && strings.EqualFold(req.Header.Get("Content-Encoding"), _encodingGzip) {
+			if err := ctx.decompressBody(); isBodyTooLarge(err) {
+				ctx.AbortWithError(http.StatusRequestEntityTooLarge, xerror.RequestEntityTooLarge)
+				return
+			} else if err != nil {
+				ctx.AbortWithError(http.StatusBadRequest, xerror.RequestErr)
+				return
+			}
+		}
+		if req.Header.Get("Range") != "" || isUpgrade(req) {
+			return
+		}
+		encoding := acceptEncoding(req.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			return
+		}
+		addVary(ctx.Writer.Header(), "Accept-Encoding")
+		w := &compressWriter{
+			ResponseWriter: ctx.Writer,
+			conf:           conf,
+			encoding:       encoding,
+			pool:           pools[encoding],
+			status:         http.StatusOK,
+		}
+		completed := false
+		ctx.Writer = w
+		defer func() {
+			ctx.Writer = w.ResponseWriter
+			if !completed {
+				// handler panic 时丢弃尚未写出的数据，交给外层中间件处理
+				w.release()
+			}
+		}()
+		ctx.Next()
+		completed = true
+		w.finish()
+	}
+}
+
+// compressWriter 压缩响应体
+// 响应体达到 MinSize 之前先缓存在 buf 中，之后再决定是否压缩
+type compressWriter struct {
+	http.ResponseWriter
+	conf     *GzipConfig
+	encoding string
+	pool     *sync.Pool
+
+	status      int
+	buf         []byte
+	wroteHeader bool
+	hijacked    bool
+	cw          compressor
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.status = code
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.wroteHeader {
+		if w.cw != nil {
+			return w.cw.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.conf.MinSize {
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush 流式响应 (如 SSE) 不再等待 MinSize，立即开始输出
+func (w *compressWriter) Flush() {
+	if !w.wroteHeader {
+		if err := w.start(true); err != nil {
+			return
+		}
+	}
+	if w.cw != nil {
+		w.cw.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack 在尚未写入响应时允许接管连接，如 websocket
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if w.wroteHeader {
+		return nil, nil, errors.New("gzip: hijack after response written")
+	}
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("gzip: response writer does not implement http.Hijacker")
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// start 写入响应头并输出缓存的数据
+func (w *compressWriter) start(compress bool) error {
+	w.wroteHeader = true
+	header := w.Header()
+	if header.Get("Content-Type") == "" && len(w.buf) > 0 {
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if compress && w.compressible() {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		w.cw = w.pool.Get().(compressor)
+		w.cw.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.cw != nil {
+		_, err = w.cw.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+	return err
+}
+
+func (w *compressWriter) compressible() bool {
+	switch {
+	case w.status < http.StatusOK,
+		w.status == http.StatusNoContent,
+		w.status == http.StatusPartialContent,
+		w.status == http.StatusNotModified:
+		return false
+	}
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+	ctype, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	for _, t := range _compressedTypes {
+		if strings.HasPrefix(ctype, t) {
+			return false
+		}
+	}
+	for _, t := range w.conf.ContentTypes {
+		if strings.HasPrefix(ctype, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// finish 输出剩余数据并关闭压缩器
+func (w *compressWriter) finish() {
+	if w.hijacked {
+		return
+	}
+	if !w.wroteHeader {
+		w.start(len(w.buf) >= w.conf.MinSize)
+	}
+	if w.cw != nil {
+		w.cw.Close()
+	}
+	w.release()
+}
+
+func (w *compressWriter) release() {
+	w.buf = nil
+	if w.cw != nil {
+		w.cw.Reset(nil)
+		w.pool.Put(w.cw)
+		w.cw = nil
+	}
+}
+
+// decompressBody 解压 gzip 请求体，并重新解析表单
+func (ctx *Context) decompressBody() error {
+	req := ctx.Request
+	gr, err := gzip.NewReader(req.Body)
+	if err != nil {
+		return err
+	}
+	req.Body = gr
+	req.Header.Del("Content-Encoding")
+	req.Header.Del("Content-Length")
+	req.ContentLength = -1
+	req.Form, req.PostForm, req.MultipartForm = nil, nil, nil
+	if err = ctx.limitBody(); err != nil {
+		return err
+	}
+	return ctx.parseBody()
+}
+
+// acceptEncoding 根据 Accept-Encoding 选择压缩方式，优先 gzip
+func acceptEncoding(header string) string {
+	if header == "" {
+		return ""
+	}
+	qs := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		coding, q := strings.TrimSpace(part), 1.0
+		if i := strings.Index(coding, ";"); i != -1 {
+			param := strings.TrimSpace(coding[i+1:])
+			coding = strings.TrimSpace(coding[:i])
+			if strings.HasPrefix(param, "q=") {
+				f, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					continue
+				}
+				q = f
+			}
+		}
+		qs[strings.ToLower(coding)] = q
+	}
+	quality := func(coding string) float64 {
+		if q, ok := qs[coding]; ok {
+			return q
+		}
+		if q, ok := qs["*"]; ok {
+			return q
+		}
+		return 0
+	}
+	gq, dq := quality(_encodingGzip), quality(_encodingDeflate)
+	switch {
+	case gq > 0 && gq >= dq:
+		return _encodingGzip
+	case dq > 0:
+		return _encodingDeflate
+	}
+	return ""
+}
+
+// addVary 向 Vary 头中添加 value，已存在时不重复添加
+func addVary(header http.Header, value string) {
+	for _, v := range header.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f == "*" || strings.EqualFold(f, value) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", value)
+}
+
+func isUpgrade(req *http.Request) bool {
+	return strings.Contains(strings.ToLower(req.Header.Get("Connection")), "upgrade")
+}
@@ -0,0 +1,115 @@
+package linac
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGzip(t *testing.T) {
+	large := strings.Repeat("linac", 1000)
+	engine := NewEngine()
+	engine.Use(GzipWithConfig(&GzipConfig{
+		Level:             gzip.BestSpeed,
+		MinSize:           1024,
+		ContentTypes:      _defaultGzipConfig.ContentTypes,
+		DecompressRequest: true,
+	}))
+	engine.GET("/large", "gzip.large", func(ctx *Context) {
+		ctx.String(200, large)
+	})
+	engine.GET("/small", "gzip.small", func(ctx *Context) {
+		ctx.String(200, "small")
+	})
+	engine.GET("/png", "gzip.png", func(ctx *Context) {
+		ctx.Writer.Header().Set("Content-Type", "image/png")
+		ctx.Writer.Write([]byte(large))
+	})
+	engine.GET("/sse", "gzip.sse", func(ctx *Context) {
+		ctx.Writer.Header().Set("Content-Type", "text/event-stream")
+		ctx.Writer.Write([]byte("data: 1\n\n"))
+		ctx.Writer.(http.Flusher).Flush()
+	})
+	engine.POST("/echo", "gzip.echo", func(ctx *Context) {
+		ctx.String(200, "%v", ctx.Post("name"))
+	})
+
+	do := func(method, path string, header map[string]string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Should compress large response with gzip", func(t *testing.T) {
+		w := do("GET", "/large", map[string]string{"Accept-Encoding": "deflate;q=0.5, gzip"}, nil)
+		assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+		gr, err := gzip.NewReader(w.Body)
+		assert.Nil(t, err)
+		bs, _ := ioutil.ReadAll(gr)
+		assert.Equal(t, large, string(bs))
+	})
+
+	t.Run("Should compress with deflate", func(t *testing.T) {
+		w := do("GET", "/large", map[string]string{"Accept-Encoding": "deflate, gzip;q=0"}, nil)
+		assert.Equal(t, "deflate", w.Header().Get("Content-Encoding"))
+		zr, err := zlib.NewReader(w.Body)
+		assert.Nil(t, err)
+		bs, _ := ioutil.ReadAll(zr)
+		assert.Equal(t, large, string(bs))
+	})
+
+	t.Run("Should skip small, compressed, range and unaccepted responses", func(t *testing.T) {
+		w := do("GET", "/small", map[string]string{"Accept-Encoding": "gzip"}, nil)
+		assert.Equal(t, "", w.Header().Get("Content-Encoding"))
+		assert.Equal(t, "small", w.Body.String())
+		assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+
+		w = do("GET", "/png", map[string]string{"Accept-Encoding": "gzip"}, nil)
+		assert.Equal(t, "", w.Header().Get("Content-Encoding"))
+
+		w = do("GET", "/large", map[string]string{"Accept-Encoding": "gzip", "Range": "bytes=0-10"}, nil)
+		assert.Equal(t, "", w.Header().Get("Content-Encoding"))
+
+		w = do("GET", "/large", map[string]string{"Accept-Encoding": "identity"}, nil)
+		assert.Equal(t, "", w.Header().Get("Content-Encoding"))
+		assert.Equal(t, large, w.Body.String())
+	})
+
+	t.Run("Should flush streaming response immediately", func(t *testing.T) {
+		w := do("GET", "/sse", map[string]string{"Accept-Encoding": "gzip"}, nil)
+		assert.True(t, w.Flushed)
+		assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		gr, err := gzip.NewReader(w.Body)
+		assert.Nil(t, err)
+		bs, _ := ioutil.ReadAll(gr)
+		assert.Equal(t, "data: 1\n\n", string(bs))
+	})
+
+	t.Run("Should decompress gzip request body", func(t *testing.T) {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		gw.Write([]byte("name=linac"))
+		gw.Close()
+		w := do("POST", "/echo", map[string]string{
+			"Content-Type":     "application/x-www-form-urlencoded",
+			"Content-Encoding": "gzip",
+		}, buf.Bytes())
+		assert.Equal(t, "linac", w.Body.String())
+
+		w = do("POST", "/echo", map[string]string{"Content-Encoding": "gzip"}, []byte("plain"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":400`)
+	})
+}
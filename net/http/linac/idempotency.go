@@ -0,0 +1,242 @@
+package linac
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	xerror "linac/error"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	_headerIdempotencyKey = "Idempotency-Key"
+	_headerReplayed       = "Idempotent-Replayed"
+
+	// _maxIdempotencyKeyLen Idempotency-Key 的最大长度
+	_maxIdempotencyKeyLen = 255
+)
+
+var (
+	_defaultIdempotencyConfig = &IdempotencyConfig{
+		TTL:     time.Hour * time.Duration(24),
+		Methods: []string{http.MethodPost, http.MethodPatch},
+		MaxBody: 1 << 20, // 1M
+	}
+)
+
+// IdempotencyRecord 一个 Idempotency-Key 对应的请求与响应
+type IdempotencyRecord struct {
+	// Fingerprint 请求体的摘要
+	Fingerprint string
+	// Done 为 false 表示第一个请求仍在处理中
+	Done   bool
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// IdempotencyStore 幂等请求的存储
+type IdempotencyStore interface {
+	// Lock 为 key 创建处理中的记录，key 已存在时返回已有的记录与 false
+	Lock(key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool)
+	// Save 保存 key 的响应
+	Save(key string, record *IdempotencyRecord, ttl time.Duration)
+	// Delete 删除 key，处理失败时调用，允许客户端重试
+	Delete(key string)
+}
+
+// IdempotencyConfig 幂等请求中间件配置
+type IdempotencyConfig struct {
+	// Store 响应的存储，默认为内存存储
+	Store IdempotencyStore
+	// TTL 响应保存的时间，默认 24 小时
+	TTL time.Duration
+	// Methods 需要处理的请求方法，默认为 POST 与 PATCH
+	Methods []string
+	// MaxBody 保存的响应体最大字节数，超过时不保存，默认 1M
+	MaxBody int
+	// KeyFunc 区分不同调用方的 key，如用户 ID，为 nil 时所有调用方共享 Idempotency-Key，
+	// 此时不保存响应中的 Set-Cookie，避免其他调用方重放得到第一个调用方的 cookie
+	KeyFunc func(*Context) string
+}
+
+// Idempotency 使用默认配置的幂等请求中间件
+func Idempotency() Handler {
+	return IdempotencyWithConfig(nil)
+}
+
+// IdempotencyWithConfig 幂等请求中间件
+// 对携带 Idempotency-Key 的请求，以 key、路由名称与请求体摘要保存第一次的响应 (状态码、后续 handler 设置的响应头与响应体)，
+// 重复的请求直接返回保存的响应并设置 Idempotent-Replayed: true；
+// 第一次请求仍在处理中时返回 409，请求体与第一次不一致时返回 422。
+// 5xx 响应、panic 以及流式响应不会保存，客户端可以使用同一个 key 重试
+func IdempotencyWithConfig(conf *IdempotencyConfig) Handler {
+	if conf == nil {
+		conf = _defaultIdempotencyConfig
+	}
+	store := conf.Store
+	if store == nil {
+		store = NewMemoryIdempotencyStore()
+	}
+	ttl := conf.TTL
+	if ttl <= 0 {
+		ttl = _defaultIdempotencyConfig.TTL
+	}
+	methods := conf.Methods
+	if len(methods) == 0 {
+		methods = _defaultIdempotencyConfig.Methods
+	}
+	maxBody := conf.MaxBody
+	if maxBody <= 0 {
+		maxBody = _defaultIdempotencyConfig.MaxBody
+	}
+	return func(ctx *Context) {
+		key := ctx.Request.Header.Get(_headerIdempotencyKey)
+		if key == "" || !containsMethod(methods, ctx.Request.Method) {
+			return
+		}
+		if len(key) > _maxIdempotencyKeyLen {
+			ctx.AbortWithError(http.StatusUnprocessableEntity, xerror.UnprocessableEntity)
+			return
+		}
+		bodyHash, err := ctx.bodyHash()
+		if isBodyTooLarge(err) {
+			ctx.AbortWithError(http.StatusRequestEntityTooLarge, xerror.RequestEntityTooLarge)
+			return
+		} else if err != nil {
+			ctx.AbortWithError(http.StatusBadRequest, xerror.RequestErr)
+			return
+		}
+		if conf.KeyFunc != nil {
+			key = conf.KeyFunc(ctx) + "|" + key
+		}
+		key = ctx.RouteName() + "|" + key
+		fingerprint := requestFingerprint(ctx.Request, bodyHash)
+
+		record, ok := store.Lock(key, fingerprint, ttl)
+		if !ok {
+			switch {
+			case record.Fingerprint != fingerprint:
+				ctx.AbortWithError(http.StatusUnprocessableEntity, xerror.UnprocessableEntity)
+			case !record.Done:
+				ctx.AbortWithError(http.StatusConflict, xerror.Conflict)
+			default:
+				ctx.Writer.Header().Set(_headerReplayed, "true")
+				ctx.writeRecorded(record.Status, record.Header, record.Body)
+			}
+			return
+		}
+
+		w := newRecordWriter(ctx.Writer, maxBody)
+		completed := false
+		ctx.Writer = w
+		defer func() {
+			ctx.Writer = w.ResponseWriter
+			if !completed {
+				// handler panic 时删除处理中的记录
+				store.Delete(key)
+			}
+		}()
+		ctx.Next()
+		completed = true
+		status, header, respBody, ok := w.recorded()
+		if !ok || status >= http.StatusInternalServerError {
+			store.Delete(key)
+			return
+		}
+		if conf.KeyFunc == nil {
+			header.Del("Set-Cookie")
+		}
+		store.Save(key, &IdempotencyRecord{
+			Fingerprint: fingerprint,
+			Done:        true,
+			Status:      status,
+			Header:      header,
+			Body:        append([]byte(nil), respBody...),
+		}, ttl)
+	}
+}
+
+// requestFingerprint 返回请求方法、路径、query 与请求体的摘要
+// multipart 请求体在解析表单时已被读取，bodyHash 为 ctx.bodyHash() 返回的原始请求体摘要
+func requestFingerprint(req *http.Request, bodyHash string) string {
+	h := sha256.New()
+	h.Write([]byte(req.Method + " " + req.URL.RequestURI() + "\n"))
+	h.Write([]byte(bodyHash))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func containsMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// MemoryIdempotencyStore 内存幂等请求存储，定期清理过期的记录
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	records   map[string]*idempotencyEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type idempotencyEntry struct {
+	record *IdempotencyRecord
+	expire time.Time
+}
+
+// NewMemoryIdempotencyStore 返回内存幂等请求存储
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		records: make(map[string]*idempotencyEntry),
+		now:     time.Now,
+	}
+}
+
+// Lock 为 key 创建处理中的记录，key 已存在时返回已有的记录与 false
+func (s *MemoryIdempotencyStore) Lock(key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.lastSweep) >= _sweepInterval {
+		for k, e := range s.records {
+			if now.After(e.expire) {
+				delete(s.records, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	if e, ok := s.records[key]; ok && !now.After(e.expire) {
+		return e.record, false
+	}
+	s.records[key] = &idempotencyEntry{
+		record: &IdempotencyRecord{Fingerprint: fingerprint},
+		expire: now.Add(ttl),
+	}
+	return nil, true
+}
+
+// Save 保存 key 的响应
+func (s *MemoryIdempotencyStore) Save(key string, record *IdempotencyRecord, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = &idempotencyEntry{record: record, expire: s.now().Add(ttl)}
+}
+
+// Delete 删除 key
+func (s *MemoryIdempotencyStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+}
+
+// Len 返回当前保存的记录数量
+func (s *MemoryIdempotencyStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.records)
+}
@@ -0,0 +1,186 @@
+package linac
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotency(t *testing.T) {
+	var (
+		calls   int32
+		block   = make(chan struct{})
+		started = make(chan struct{}, 1)
+	)
+	store := NewMemoryIdempotencyStore()
+	engine := NewEngine()
+	engine.Use(IdempotencyWithConfig(&IdempotencyConfig{Store: store}))
+	engine.POST("/payments", "idempotency.payments", func(ctx *Context) {
+		n := atomic.AddInt32(&calls, 1)
+		if ctx.Request.URL.Query().Get("slow") != "" {
+			started <- struct{}{}
+			<-block
+		}
+		if ctx.Request.URL.Query().Get("fail") != "" {
+			ctx.String(http.StatusInternalServerError, "fail")
+			return
+		}
+		ctx.Writer.Header().Set("X-Payment", "p1")
+		ctx.Writer.WriteHeader(http.StatusCreated)
+		ctx.Writer.Write([]byte(strings.Repeat("x", int(n))))
+	})
+
+	request := func(target, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("replay", func(t *testing.T) {
+		w := request("/payments", "k1", `{"amount":1}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "x", w.Body.String())
+		assert.Equal(t, "", w.Header().Get("Idempotent-Replayed"))
+
+		w = request("/payments", "k1", `{"amount":1}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "x", w.Body.String())
+		assert.Equal(t, "p1", w.Header().Get("X-Payment"))
+		assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("fingerprint mismatch", func(t *testing.T) {
+		w := request("/payments", "k1", `{"amount":2}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":422`)
+	})
+
+	t.Run("multipart fingerprint", func(t *testing.T) {
+		multipartRequest := func(content string) *httptest.ResponseRecorder {
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			mw.SetBoundary("idempotency")
+			fw, _ := mw.CreateFormFile("file", "a.txt")
+			fw.Write([]byte(content))
+			mw.Close()
+			req := httptest.NewRequest(http.MethodPost, "/payments", &body)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			req.Header.Set("Idempotency-Key", "k4")
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			return w
+		}
+		assert.Equal(t, http.StatusCreated, multipartRequest("a").Code)
+		w := multipartRequest("a")
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+		w = multipartRequest("b")
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("invalid body", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/payments", errReader{})
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", "k5")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":400`)
+	})
+
+	t.Run("without key", func(t *testing.T) {
+		before := atomic.LoadInt32(&calls)
+		request("/payments", "", `{"amount":1}`)
+		assert.Equal(t, before+1, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("in flight", func(t *testing.T) {
+		done := make(chan *httptest.ResponseRecorder)
+		go func() {
+			done <- request("/payments?slow=1", "k2", `{}`)
+		}()
+		<-started
+		w := request("/payments?slow=1", "k2", `{}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":409`)
+		close(block)
+		assert.Equal(t, http.StatusCreated, (<-done).Code)
+	})
+
+	t.Run("server error is not saved", func(t *testing.T) {
+		w := request("/payments?fail=1", "k3", `{}`)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		before := atomic.LoadInt32(&calls)
+		request("/payments?fail=1", "k3", `{}`)
+		assert.Equal(t, before+1, atomic.LoadInt32(&calls))
+	})
+}
+
+func TestIdempotencyOuterHeaders(t *testing.T) {
+	var calls int32
+	engine := NewEngine()
+	engine.Use(Trace(), Idempotency())
+	engine.POST("/orders", "idempotency.orders", func(ctx *Context) {
+		n := atomic.AddInt32(&calls, 1)
+		http.SetCookie(ctx.Writer, &http.Cookie{Name: "session", Value: "s1"})
+		ctx.Writer.Header().Set("X-Order", "o1")
+		ctx.String(http.StatusCreated, "%d", n)
+	})
+	request := func(requestID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{}`))
+		req.Header.Set("Idempotency-Key", "k1")
+		req.Header.Set(_headerRequestID, requestID)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("req-1")
+	assert.Equal(t, "session=s1", w.Header().Get("Set-Cookie"))
+	w = request("req-2")
+	assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, "1", w.Body.String())
+	assert.Equal(t, "req-2", w.Header().Get(_headerRequestID))
+	assert.Equal(t, "o1", w.Header().Get("X-Order"))
+	// 没有 KeyFunc 时不重放其他调用方的 cookie
+	assert.Equal(t, "", w.Header().Get("Set-Cookie"))
+}
+
+// errReader 读取时总是返回错误
+type errReader struct{}
+
+func (errReader) Read(p []byte) (int, error) {
+	return 0, errors.New("read error")
+}
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	s := NewMemoryIdempotencyStore()
+	s.now = clock.Now
+
+	_, ok := s.Lock("k", "f1", time.Minute)
+	assert.True(t, ok)
+	record, ok := s.Lock("k", "f2", time.Minute)
+	assert.False(t, ok)
+	assert.Equal(t, "f1", record.Fingerprint)
+	assert.False(t, record.Done)
+
+	clock.now = clock.now.Add(time.Minute * 2)
+	_, ok = s.Lock("k", "f2", time.Minute)
+	assert.True(t, ok)
+	assert.Equal(t, 1, s.Len())
+}
@@ -0,0 +1,356 @@
+package linac
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// _jwksCheckInterval 检查 JWKS 文件是否变化的默认间隔
+	_jwksCheckInterval = time.Minute
+	// _jwksMissInterval 遇到未知 kid 时强制检查 JWKS 文件的最小间隔
+	_jwksMissInterval = time.Second
+)
+
+// JWT 验证错误
+var (
+	ErrTokenMalformed   = errors.New("jwt: token is malformed")
+	ErrTokenAlgorithm   = errors.New("jwt: signing algorithm is not allowed")
+	ErrTokenSignature   = errors.New("jwt: signature is invalid")
+	ErrTokenExpired     = errors.New("jwt: token is expired")
+	ErrTokenNotValidYet = errors.New("jwt: token is not valid yet")
+	ErrTokenIssuer      = errors.New("jwt: issuer is invalid")
+	ErrTokenAudience    = errors.New("jwt: audience is invalid")
+	ErrKeyNotFound      = errors.New("jwt: key not found")
+)
+
+// KeySet 根据 JWT 头部的 kid 与 alg 返回验证签名的密钥
+// HS256 的密钥为 []byte，RS256 的密钥为 *rsa.PublicKey
+type KeySet interface {
+	Key(kid, alg string) (interface{}, error)
+}
+
+type staticKey struct {
+	key interface{}
+}
+
+// StaticKey 返回只有一个密钥的 KeySet，忽略 kid
+func StaticKey(key interface{}) KeySet {
+	return &staticKey{key: key}
+}
+
+func (s *staticKey) Key(kid, alg string) (interface{}, error) {
+	return s.key, nil
+}
+
+// JWTConfig JWT 认证配置
+type JWTConfig struct {
+	// Keys 验证签名的密钥，必须设置
+	Keys KeySet
+	// Algorithms 允许的签名算法，默认为 HS256 与 RS256
+	Algorithms []string
+	// Issuer 不为空时检查 iss
+	Issuer string
+	// Audience 不为空时检查 aud 至少包含其中一个
+	Audience []string
+	// Leeway 检查 exp 与 nbf 时允许的时钟误差
+	Leeway time.Duration
+	// Authorize 认证通过后检查权限，返回 false 时响应 403，为 nil 时不检查
+	Authorize func(ctx *Context, claims Claims) bool
+
+	now func() time.Time
+}
+
+// JWT Bearer JWT 认证中间件
+// 从 Authorization: Bearer <token> 中读取 token，验证失败返回 401，
+// 验证通过后 payload 保存在 ctx.Claims() 中
+func JWT(conf *JWTConfig) Handler {
+	if conf.Keys == nil {
+		panic("jwt: keys must not be nil")
+	}
+	return func(ctx *Context) {
+		auth := ctx.Request.Header.Get(_headerAuthorization)
+		if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+			ctx.unauthorized("Bearer")
+			return
+		}
+		claims, err := ParseJWT(strings.TrimSpace(auth[7:]), conf)
+		if err != nil {
+			ctx.unauthorized(`Bearer error="invalid_token"`)
+			return
+		}
+		ctx.setClaims(claims, conf.Authorize)
+	}
+}
+
+// ParseJWT 验证 token 的签名与 exp、nbf、iss、aud，返回 payload
+func ParseJWT(token string, conf *JWTConfig) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if !allowAlgorithm(conf.Algorithms, header.Alg) {
+		return nil, ErrTokenAlgorithm
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	key, err := conf.Keys.Key(header.Kid, header.Alg)
+	if err != nil {
+		return nil, err
+	}
+	if err = verifySignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+	var claims Claims
+	if err = decodeSegment(parts[1], &claims); err != nil || claims == nil {
+		return nil, ErrTokenMalformed
+	}
+	if err = conf.validate(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (conf *JWTConfig) validate(claims Claims) error {
+	now := time.Now()
+	if conf.now != nil {
+		now = conf.now()
+	}
+	if _, ok := claims["exp"]; ok {
+		exp := claims.ExpiresAt()
+		if exp.IsZero() || !now.Before(exp.Add(conf.Leeway)) {
+			return ErrTokenExpired
+		}
+	}
+	if _, ok := claims["nbf"]; ok {
+		nbf := claims.NotBefore()
+		if nbf.IsZero() || now.Add(conf.Leeway).Before(nbf) {
+			return ErrTokenNotValidYet
+		}
+	}
+	if conf.Issuer != "" && claims.Issuer() != conf.Issuer {
+		return ErrTokenIssuer
+	}
+	if len(conf.Audience) > 0 {
+		for _, aud := range claims.Audience() {
+			for _, expected := range conf.Audience {
+				if aud == expected {
+					return nil
+				}
+			}
+		}
+		return ErrTokenAudience
+	}
+	return nil
+}
+
+func allowAlgorithm(algs []string, alg string) bool {
+	if len(algs) == 0 {
+		algs = []string{"HS256", "RS256"}
+	}
+	for _, a := range algs {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
+// verifySignature 验证签名，密钥类型必须与算法匹配，避免用 RSA 公钥作为 HMAC 密钥伪造签名
+func verifySignature(alg string, key interface{}, signing string, sig []byte) error {
+	switch alg {
+	case "HS256":
+		secret, ok := key.([]byte)
+		if !ok {
+			return ErrKeyNotFound
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signing))
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return ErrTokenSignature
+		}
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrKeyNotFound
+		}
+		sum := sha256.Sum256([]byte(signing))
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig) != nil {
+			return ErrTokenSignature
+		}
+	default:
+		return ErrTokenAlgorithm
+	}
+	return nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	bs, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, v)
+}
+
+// JWKS JSON Web Key Set，支持 RSA 与 oct 类型的密钥
+type JWKS struct {
+	path     string
+	interval time.Duration
+
+	mu        sync.RWMutex
+	keys      map[string]jwk
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+type jwk struct {
+	alg string
+	key interface{}
+}
+
+// ParseJWKS 解析 JWKS 文档
+func ParseJWKS(data []byte) (*JWKS, error) {
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, err
+	}
+	return &JWKS{keys: keys}, nil
+}
+
+// LoadJWKSFile 从本地文件加载 JWKS
+// 每隔 interval 检查一次文件的修改时间，变化时重新加载，实现密钥轮换；
+// 遇到未知的 kid 时会立即检查。interval <= 0 时为 1 分钟
+func LoadJWKSFile(path string, interval time.Duration) (*JWKS, error) {
+	if interval <= 0 {
+		interval = _jwksCheckInterval
+	}
+	s := &JWKS{path: path, interval: interval}
+	if err := s.reload(time.Now()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Key 实现 KeySet
+// kid 为空且只有一个密钥时返回该密钥
+func (s *JWKS) Key(kid, alg string) (interface{}, error) {
+	if s.path != "" {
+		s.check(kid)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.keys[kid]
+	if !ok && kid == "" && len(s.keys) == 1 {
+		for _, k = range s.keys {
+			ok = true
+		}
+	}
+	if !ok || k.alg != "" && k.alg != alg {
+		return nil, ErrKeyNotFound
+	}
+	return k.key, nil
+}
+
+// check 到达检查间隔或 kid 不存在时检查文件是否变化
+func (s *JWKS) check(kid string) {
+	now := time.Now()
+	s.mu.RLock()
+	_, known := s.keys[kid]
+	elapsed := now.Sub(s.lastCheck)
+	s.mu.RUnlock()
+	if elapsed < s.interval && (known || elapsed < _jwksMissInterval) {
+		return
+	}
+	// NOTE: 重新加载失败时继续使用旧的密钥
+	s.reload(now)
+}
+
+func (s *JWKS) reload(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastCheck = now
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	if s.keys != nil && fi.ModTime().Equal(s.modTime) {
+		return nil
+	}
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+	s.keys, s.modTime = keys, fi.ModTime()
+	return nil
+}
+
+func parseJWKS(data []byte) (map[string]jwk, error) {
+	var doc struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Alg string `json:"alg"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			K   string `json:"k"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]jwk, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(k.N)
+			if err != nil {
+				return nil, errors.New("jwks: invalid rsa modulus of key " + k.Kid)
+			}
+			e, err := base64.RawURLEncoding.DecodeString(k.E)
+			if err != nil || len(e) == 0 || len(e) > 4 {
+				return nil, errors.New("jwks: invalid rsa exponent of key " + k.Kid)
+			}
+			e = append(bytes.Repeat([]byte{0}, 4-len(e)), e...)
+			keys[k.Kid] = jwk{alg: k.Alg, key: &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(e[0])<<24 | int(e[1])<<16 | int(e[2])<<8 | int(e[3]),
+			}}
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(k.K)
+			if err != nil {
+				return nil, errors.New("jwks: invalid secret of key " + k.Kid)
+			}
+			keys[k.Kid] = jwk{alg: k.Alg, key: secret}
+		}
+	}
+	return keys, nil
+}
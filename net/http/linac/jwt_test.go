@@ -0,0 +1,159 @@
+package linac
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func signJWT(t *testing.T, alg, kid string, key interface{}, claims Claims) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signing := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signing))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		sum := sha256.Sum256([]byte(signing))
+		var err error
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, sum[:])
+		assert.Nil(t, err)
+	}
+	return signing + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func writeJWKS(t *testing.T, path string, keys map[string]*rsa.PrivateKey) {
+	var doc struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	for kid, k := range keys {
+		doc.Keys = append(doc.Keys, map[string]string{
+			"kty": "RSA",
+			"kid": kid,
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		})
+	}
+	bs, _ := json.Marshal(doc)
+	assert.Nil(t, ioutil.WriteFile(path, bs, 0644))
+}
+
+func TestParseJWT(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Unix(1700000000, 0)
+	conf := &JWTConfig{
+		Keys:     StaticKey(secret),
+		Issuer:   "linac",
+		Audience: []string{"api"},
+		Leeway:   time.Second * 5,
+		now:      func() time.Time { return now },
+	}
+	valid := Claims{"sub": "u1", "iss": "linac", "aud": []string{"web", "api"}, "exp": now.Unix() + 60, "nbf": now.Unix()}
+
+	cases := []struct {
+		name   string
+		alg    string
+		key    interface{}
+		modify func(Claims)
+		err    error
+	}{
+		{name: "valid", alg: "HS256", key: secret},
+		{name: "leeway", alg: "HS256", key: secret, modify: func(c Claims) { c["exp"] = now.Unix() - 3 }},
+		{name: "expired", alg: "HS256", key: secret, modify: func(c Claims) { c["exp"] = now.Unix() - 10 }, err: ErrTokenExpired},
+		{name: "not before", alg: "HS256", key: secret, modify: func(c Claims) { c["nbf"] = now.Unix() + 10 }, err: ErrTokenNotValidYet},
+		{name: "issuer", alg: "HS256", key: secret, modify: func(c Claims) { c["iss"] = "other" }, err: ErrTokenIssuer},
+		{name: "audience", alg: "HS256", key: secret, modify: func(c Claims) { c["aud"] = "web" }, err: ErrTokenAudience},
+		{name: "signature", alg: "HS256", key: []byte("other"), err: ErrTokenSignature},
+		{name: "none", alg: "none", key: secret, err: ErrTokenAlgorithm},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			claims := Claims{}
+			for k, v := range valid {
+				claims[k] = v
+			}
+			if c.modify != nil {
+				c.modify(claims)
+			}
+			got, err := ParseJWT(signJWT(t, c.alg, "", c.key, claims), conf)
+			assert.Equal(t, c.err, err)
+			if err == nil {
+				assert.Equal(t, "u1", got.Subject())
+			}
+		})
+	}
+
+	t.Run("malformed", func(t *testing.T) {
+		_, err := ParseJWT("a.b", conf)
+		assert.Equal(t, ErrTokenMalformed, err)
+	})
+}
+
+func TestJWKSRotation(t *testing.T) {
+	key1, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	key2, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	dir, err := ioutil.TempDir("", "jwks")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "jwks.json")
+	writeJWKS(t, path, map[string]*rsa.PrivateKey{"k1": key1})
+
+	jwks, err := LoadJWKSFile(path, time.Hour)
+	assert.Nil(t, err)
+	conf := &JWTConfig{Keys: jwks}
+	claims := Claims{"sub": "u1"}
+
+	_, err = ParseJWT(signJWT(t, "RS256", "k1", key1, claims), conf)
+	assert.Nil(t, err)
+	// RSA 公钥不能作为 HMAC 密钥使用
+	_, err = ParseJWT(signJWT(t, "HS256", "k1", []byte("k1"), claims), conf)
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// 轮换密钥后未知的 kid 会触发重新加载
+	writeJWKS(t, path, map[string]*rsa.PrivateKey{"k1": key1, "k2": key2})
+	future := time.Now().Add(time.Minute)
+	assert.Nil(t, os.Chtimes(path, future, future))
+	jwks.lastCheck = time.Now().Add(-_jwksMissInterval)
+	_, err = ParseJWT(signJWT(t, "RS256", "k2", key2, claims), conf)
+	assert.Nil(t, err)
+
+	engine := NewEngine()
+	engine.Use(JWT(conf))
+	engine.GET("/jwt", "auth.jwt", func(ctx *Context) {
+		ctx.String(http.StatusOK, ctx.Claims().Subject())
+	})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/jwt", nil)
+	req.Header.Set("Authorization", "Bearer "+signJWT(t, "RS256", "k2", key2, claims))
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "u1", w.Body.String())
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/jwt", nil)
+	req.Header.Set("Authorization", "Bearer "+signJWT(t, "RS256", "k3", key2, claims))
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Bearer error="invalid_token"`, w.Header().Get("WWW-Authenticate"))
+}
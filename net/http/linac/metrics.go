@@ -0,0 +1,131 @@
+package linac
+
+import (
+	"context"
+	xerror "linac/error"
+	"linac/stat/metric"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	_metricNamespace = "linac"
+	_metricSubsystem = "http_server"
+
+	// _contentMetrics Prometheus 文本格式的 content type
+	_contentMetrics = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+var (
+	_metricRequests = metric.NewCounterVec(&metric.VectorOpts{
+		Namespace: _metricNamespace,
+		Subsystem: _metricSubsystem,
+		Name:      "requests_total",
+		Help:      "http server requests total.",
+		Labels:    []string{"route", "method", "status", "code"},
+	})
+	_metricDuration = metric.NewHistogramVec(&metric.VectorOpts{
+		Namespace: _metricNamespace,
+		Subsystem: _metricSubsystem,
+		Name:      "request_duration_seconds",
+		Help:      "http server requests duration(s).",
+		Labels:    []string{"route", "method", "code"},
+	})
+	_metricInFlight = metric.NewGaugeVec(&metric.VectorOpts{
+		Namespace: _metricNamespace,
+		Subsystem: _metricSubsystem,
+		Name:      "requests_in_flight",
+		Help:      "http server requests in flight.",
+		Labels:    []string{"route"},
+	})
+	_metricResponseSize = metric.NewSummaryVec(&metric.VectorOpts{
+		Namespace: _metricNamespace,
+		Subsystem: _metricSubsystem,
+		Name:      "response_size_bytes",
+		Help:      "http server response size(bytes).",
+		Labels:    []string{"route", "method", "code"},
+	})
+	_metricTimeouts = metric.NewCounterVec(&metric.VectorOpts{
+		Namespace: _metricNamespace,
+		Subsystem: _metricSubsystem,
+		Name:      "timeouts_total",
+		Help:      "http server requests exceeded timeout total.",
+		Labels:    []string{"route"},
+	})
+	_metricPanics = metric.NewCounterVec(&metric.VectorOpts{
+		Namespace: _metricNamespace,
+		Subsystem: _metricSubsystem,
+		Name:      "panics_total",
+		Help:      "http server handler panics total.",
+		Labels:    []string{"route"},
+	})
+)
+
+// EnableMetrics 为 engine 开启请求指标，并以 name 为路由名称在 path 上以 Prometheus 文本格式输出指标
+// NOTE: 只对调用之后注册的路由有效，应在注册路由之前调用
+func (engine *Engine) EnableMetrics(path, name string) {
+	engine.Use(Metrics())
+	engine.GET(path, name, MetricsHandler())
+}
+
+// Metrics RED 指标中间件
+// 按路由名称统计请求数、耗时、处理中的请求数以及响应大小，并以 linac/error 的错误码作为标签
+// 未匹配到路由的请求不统计，非标准的请求方法记为 OTHER，避免客户端制造任意多的标签
+func Metrics() Handler {
+	return func(ctx *Context) {
+		if ctx.route == nil {
+			ctx.Next()
+			return
+		}
+		route, method := ctx.RouteName(), metricMethod(ctx.Request.Method)
+		start := time.Now()
+		w := newStatusWriter(ctx.Writer)
+		ctx.Writer = w
+		_metricInFlight.Inc(route)
+		completed := false
+		defer func() {
+			ctx.Writer = w.ResponseWriter
+			_metricInFlight.Dec(route)
+			status, code := w.status, xerror.Cause(ctx.Error).Code()
+			if !completed {
+				// handler panic 时由 Recovery 返回 500
+				status, code = http.StatusInternalServerError, xerror.ServerErr.Code()
+			}
+			codeLabel := strconv.Itoa(code)
+			_metricRequests.Inc(route, method, strconv.Itoa(status), codeLabel)
+			_metricDuration.Observe(time.Since(start).Seconds(), route, method, codeLabel)
+			_metricResponseSize.Observe(float64(w.size), route, method, codeLabel)
+		}()
+		ctx.Next()
+		completed = true
+	}
+}
+
+// metricMethod 返回请求方法的标签值，非标准方法统一为 OTHER
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+// MetricsHandler 以 Prometheus 文本格式输出 metric.DefaultRegistry 中的指标
+func MetricsHandler() Handler {
+	return func(ctx *Context) {
+		ctx.writeContentType(_contentMetrics)
+		ctx.Writer.WriteHeader(http.StatusOK)
+		if _, err := metric.DefaultRegistry.WriteTo(ctx.Writer); err != nil {
+			ctx.Error = err
+		}
+	}
+}
+
+// observeTimeout 统计执行超时的请求
+func observeTimeout(ctx *Context) {
+	if ctx.Err() == context.DeadlineExceeded {
+		_metricTimeouts.Inc(ctx.RouteName())
+	}
+}
@@ -0,0 +1,51 @@
+package linac
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	engine := NewEngine()
+	engine.EnableMetrics("/metrics", "internal.metrics")
+	engine.GET("/metrics-ok", "metrics.ok", func(ctx *Context) {
+		ctx.String(200, "ok")
+	})
+	engine.GET("/metrics-panic", "metrics.panic", func(ctx *Context) {
+		panic("boom")
+	})
+	engine.GET("/metrics-timeout", "metrics.timeout", func(ctx *Context) {
+		<-ctx.Done()
+		ctx.String(200, "timeout")
+	}).SetConfig(&RouteConfig{Timeout: time.Millisecond})
+
+	engine.addRoute("/metrics-purge", "PURGE", "metrics.purge", func(ctx *Context) {
+		ctx.String(200, "purge")
+	})
+
+	for _, path := range []string{"/metrics-ok", "/metrics-ok", "/metrics-panic", "/metrics-timeout", "/metrics-none"} {
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	for _, method := range []string{"PURGE", "X-RANDOM-1"} {
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/metrics-purge", nil))
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	assert.Equal(t, _contentMetrics, w.Header().Get("Content-Type"))
+	assert.Contains(t, body, `linac_http_server_requests_total{route="metrics.ok",method="GET",status="200",code="0"} 2`)
+	assert.Contains(t, body, `linac_http_server_requests_total{route="metrics.panic",method="GET",status="500",code="500"} 1`)
+	assert.Contains(t, body, `linac_http_server_request_duration_seconds_count{route="metrics.ok",method="GET",code="0"} 2`)
+	assert.Contains(t, body, `linac_http_server_response_size_bytes_sum{route="metrics.ok",method="GET",code="0"} 4`)
+	assert.Contains(t, body, `linac_http_server_requests_in_flight{route="metrics.ok"} 0`)
+	assert.Contains(t, body, `linac_http_server_requests_in_flight{route="internal.metrics"} 1`)
+	assert.Contains(t, body, `linac_http_server_panics_total{route="metrics.panic"} 1`)
+	assert.Contains(t, body, `linac_http_server_timeouts_total{route="metrics.timeout"} 1`)
+	assert.Contains(t, body, `linac_http_server_requests_total{route="metrics.purge",method="OTHER",status="200",code="0"} 1`)
+	assert.NotContains(t, body, `route=""`)
+	assert.NotContains(t, body, `method="PURGE"`)
+	assert.NotContains(t, body, `X-RANDOM-1`)
+}
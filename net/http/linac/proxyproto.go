@@ -0,0 +1,219 @@
+package linac
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// _proxyHeaderTimeout 读取 PROXY protocol 头部的默认超时时间
+	_proxyHeaderTimeout = time.Second * 5
+	// _proxyV1MaxLen v1 头部的最大长度，包含结尾的 \r\n
+	_proxyV1MaxLen = 107
+)
+
+var (
+	// _proxyV1Prefix v1 头部的前缀
+	_proxyV1Prefix = []byte("PROXY ")
+	// _proxyV2Signature v2 头部的签名
+	_proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	errProxyHeader = errors.New("proxy protocol: invalid header")
+)
+
+// ProxyProtocolConfig PROXY protocol 配置
+type ProxyProtocolConfig struct {
+	// TrustedSources 发送 PROXY protocol 头部的负载均衡 IP 或 CIDR，
+	// 来自这些地址的连接必须以 PROXY protocol 头部开始，其他连接不解析头部
+	TrustedSources []string
+	// HeaderTimeout 读取头部的超时时间，默认 5 秒
+	HeaderTimeout time.Duration
+}
+
+// NewProxyListener 返回解析 PROXY protocol v1/v2 头部的 listener
+// 来自可信地址的连接的 RemoteAddr 为头部中的客户端地址，头部格式错误或超时的连接会被关闭
+func NewProxyListener(l net.Listener, conf *ProxyProtocolConfig) (net.Listener, error) {
+	trusted, err := parseCIDRs(conf.TrustedSources)
+	if err != nil {
+		return nil, err
+	}
+	timeout := conf.HeaderTimeout
+	if timeout <= 0 {
+		timeout = _proxyHeaderTimeout
+	}
+	return &proxyListener{Listener: l, trusted: trusted, timeout: timeout}, nil
+}
+
+type proxyListener struct {
+	net.Listener
+	trusted []*net.IPNet
+	timeout time.Duration
+}
+
+// Accept 不在这里读取头部，避免慢连接阻塞 accept 循环
+func (l *proxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); !ok || !containsIP(l.trusted, addr.IP) {
+		return conn, nil
+	}
+	return &proxyConn{Conn: conn, r: bufio.NewReader(conn), timeout: l.timeout}, nil
+}
+
+// proxyConn 第一次读取或获取 RemoteAddr 时解析头部
+type proxyConn struct {
+	net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+
+	once   sync.Once
+	remote net.Addr
+	local  net.Addr
+	err    error
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *proxyConn) LocalAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.local != nil {
+		return c.local
+	}
+	return c.Conn.LocalAddr()
+}
+
+func (c *proxyConn) readHeader() {
+	c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+	defer c.Conn.SetReadDeadline(time.Time{})
+	// NOTE: 先读取 v1 前缀长度的内容，最短的 v1 头部 "PROXY UNKNOWN\r\n" 只有 15 字节，
+	// 按 v2 签名的长度读取可能等待客户端不会发送的数据；v1 前缀与 v2 签名的开头不同，可以据此区分版本
+	prefix, err := c.r.Peek(len(_proxyV1Prefix))
+	switch {
+	case err != nil:
+		c.err = err
+	case bytes.Equal(prefix, _proxyV1Prefix):
+		c.remote, c.local, c.err = readProxyV1(c.r)
+	case !bytes.HasPrefix(_proxyV2Signature, prefix):
+		c.err = errProxyHeader
+	default:
+		sig, err := c.r.Peek(len(_proxyV2Signature))
+		switch {
+		case err != nil:
+			c.err = err
+		case !bytes.Equal(sig, _proxyV2Signature):
+			c.err = errProxyHeader
+		default:
+			c.remote, c.local, c.err = readProxyV2(c.r)
+		}
+	}
+	if c.err != nil {
+		// NOTE: 头部错误的连接直接关闭，http server 读取时会得到错误
+		c.Conn.Close()
+	}
+}
+
+// readProxyV1 解析 v1 文本头部，如 "PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n"
+func readProxyV1(r *bufio.Reader) (remote, local net.Addr, err error) {
+	var line []byte
+	for len(line) < _proxyV1MaxLen {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, errProxyHeader
+	}
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(fields) != 6 || fields[1] != "TCP4" && fields[1] != "TCP6" {
+		return nil, nil, errProxyHeader
+	}
+	src, dst := net.ParseIP(fields[2]), net.ParseIP(fields[3])
+	if src == nil || dst == nil || (src.To4() != nil) != (fields[1] == "TCP4") || (dst.To4() != nil) != (fields[1] == "TCP4") {
+		return nil, nil, errProxyHeader
+	}
+	sport, err1 := parseProxyPort(fields[4])
+	dport, err2 := parseProxyPort(fields[5])
+	if err1 != nil || err2 != nil {
+		return nil, nil, errProxyHeader
+	}
+	return &net.TCPAddr{IP: src, Port: sport}, &net.TCPAddr{IP: dst, Port: dport}, nil
+}
+
+// readProxyV2 解析 v2 二进制头部
+func readProxyV2(r *bufio.Reader) (remote, local net.Addr, err error) {
+	var header [16]byte
+	if _, err = io.ReadFull(r, header[:]); err != nil {
+		return nil, nil, err
+	}
+	if header[12]>>4 != 2 {
+		return nil, nil, errProxyHeader
+	}
+	body := make([]byte, binary.BigEndian.Uint16(header[14:]))
+	if _, err = io.ReadFull(r, body); err != nil {
+		return nil, nil, err
+	}
+	switch header[12] & 0x0f {
+	case 0x00: // LOCAL，如负载均衡的健康检查，使用原始地址
+		return nil, nil, nil
+	case 0x01: // PROXY
+	default:
+		return nil, nil, errProxyHeader
+	}
+	var ipLen int
+	switch header[13] >> 4 {
+	case 0x1: // AF_INET
+		ipLen = net.IPv4len
+	case 0x2: // AF_INET6
+		ipLen = net.IPv6len
+	default: // AF_UNSPEC、AF_UNIX 使用原始地址
+		return nil, nil, nil
+	}
+	if len(body) < ipLen*2+4 {
+		return nil, nil, errProxyHeader
+	}
+	src := net.IP(append([]byte(nil), body[:ipLen]...))
+	dst := net.IP(append([]byte(nil), body[ipLen:ipLen*2]...))
+	sport := int(binary.BigEndian.Uint16(body[ipLen*2:]))
+	dport := int(binary.BigEndian.Uint16(body[ipLen*2+2:]))
+	return &net.TCPAddr{IP: src, Port: sport}, &net.TCPAddr{IP: dst, Port: dport}, nil
+}
+
+func parseProxyPort(s string) (int, error) {
+	port, err := strconv.ParseUint(s, 10, 16)
+	if err != nil || len(s) > 1 && s[0] == '0' {
+		return 0, errProxyHeader
+	}
+	return int(port), nil
+}
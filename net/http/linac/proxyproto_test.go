@@ -0,0 +1,142 @@
+package linac
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func serveProxy(t *testing.T, conf *ProxyProtocolConfig) (addr string, closeFn func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	pl, err := NewProxyListener(l, conf)
+	assert.Nil(t, err)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.RemoteAddr))
+	})}
+	go srv.Serve(pl)
+	return l.Addr().String(), func() { srv.Close() }
+}
+
+func proxyRequest(t *testing.T, addr string, header []byte) (string, error) {
+	conn, err := net.Dial("tcp", addr)
+	assert.Nil(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second * 2))
+	conn.Write(header)
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: example.com\r\nConnection: close\r\n\r\n"))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func proxyV2Header(cmd byte, src, dst net.IP, sport, dport uint16) []byte {
+	var buf bytes.Buffer
+	buf.Write(_proxyV2Signature)
+	buf.WriteByte(0x20 | cmd)
+	body := make([]byte, 0, 36)
+	fam := byte(0x11)
+	if src.To4() == nil {
+		fam = 0x21
+		body = append(append(body, src.To16()...), dst.To16()...)
+	} else {
+		body = append(append(body, src.To4()...), dst.To4()...)
+	}
+	body = append(body, byte(sport>>8), byte(sport), byte(dport>>8), byte(dport))
+	buf.WriteByte(fam)
+	binary.Write(&buf, binary.BigEndian, uint16(len(body)))
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+func TestProxyProtocol(t *testing.T) {
+	addr, closeFn := serveProxy(t, &ProxyProtocolConfig{
+		TrustedSources: []string{"127.0.0.1"},
+		HeaderTimeout:  time.Millisecond * 200,
+	})
+	defer closeFn()
+
+	cases := []struct {
+		name   string
+		header []byte
+		remote string
+		fail   bool
+	}{
+		{name: "v1 tcp4", header: []byte("PROXY TCP4 1.2.3.4 10.0.0.1 56324 443\r\n"), remote: "1.2.3.4:56324"},
+		{name: "v1 tcp6", header: []byte("PROXY TCP6 2001:db8::1 2001:db8::2 4711 443\r\n"), remote: "[2001:db8::1]:4711"},
+		{name: "v1 unknown", header: []byte("PROXY UNKNOWN\r\n"), remote: "127.0.0.1:"},
+		{name: "v2 ipv4", header: proxyV2Header(0x01, net.ParseIP("5.6.7.8"), net.ParseIP("10.0.0.1"), 1234, 80), remote: "5.6.7.8:1234"},
+		{name: "v2 ipv6", header: proxyV2Header(0x01, net.ParseIP("2001:db8::3"), net.ParseIP("2001:db8::4"), 1234, 80), remote: "[2001:db8::3]:1234"},
+		{name: "v2 local", header: proxyV2Header(0x00, net.ParseIP("5.6.7.8"), net.ParseIP("10.0.0.1"), 1234, 80), remote: "127.0.0.1:"},
+		{name: "missing header", header: nil, fail: true},
+		{name: "malformed v1", header: []byte("PROXY TCP4 1.2.3.4 10.0.0.1 port 443\r\n"), fail: true},
+		{name: "mismatched family", header: []byte("PROXY TCP4 2001:db8::1 10.0.0.1 1 443\r\n"), fail: true},
+		{name: "invalid v2 signature", header: []byte("\r\n\r\n\x00\r\nQUIX\n\x21\x11\x00\x00"), fail: true},
+		{name: "too long", header: []byte("PROXY TCP4 " + strings.Repeat("1", 120) + "\r\n"), fail: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			remote, err := proxyRequest(t, addr, c.header)
+			if c.fail {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.True(t, strings.HasPrefix(remote, c.remote), remote)
+		})
+	}
+
+	t.Run("v1 unknown without request", func(t *testing.T) {
+		conn, err := net.Dial("tcp", addr)
+		assert.Nil(t, err)
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(time.Second * 2))
+		// 只发送头部，等待超过 HeaderTimeout 后再发送请求，头部应当已经解析完成
+		conn.Write([]byte("PROXY UNKNOWN\r\n"))
+		time.Sleep(time.Millisecond * 300)
+		conn.Write([]byte("GET / HTTP/1.1\r\nHost: example.com\r\nConnection: close\r\n\r\n"))
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		assert.Nil(t, err)
+		if err == nil {
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+	})
+
+	t.Run("header timeout", func(t *testing.T) {
+		conn, err := net.Dial("tcp", addr)
+		assert.Nil(t, err)
+		defer conn.Close()
+		conn.Write([]byte("PROXY TCP4"))
+		conn.SetReadDeadline(time.Now().Add(time.Second * 2))
+		start := time.Now()
+		_, err = conn.Read(make([]byte, 1))
+		assert.NotNil(t, err)
+		assert.True(t, time.Since(start) < time.Second)
+	})
+}
+
+func TestProxyProtocolUntrusted(t *testing.T) {
+	addr, closeFn := serveProxy(t, &ProxyProtocolConfig{TrustedSources: []string{"10.0.0.0/8"}})
+	defer closeFn()
+
+	remote, err := proxyRequest(t, addr, nil)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(remote, "127.0.0.1:"), remote)
+
+	// 不可信来源的头部不会被解析，作为错误的 http 请求处理
+	remote, _ = proxyRequest(t, addr, []byte("PROXY TCP4 1.2.3.4 10.0.0.1 56324 443\r\n"))
+	assert.False(t, strings.HasPrefix(remote, "1.2.3.4"), remote)
+}
@@ -0,0 +1,271 @@
+package linac
+
+import (
+	xerror "linac/error"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// _sweepInterval 清理过期 key 的间隔
+	_sweepInterval = time.Minute
+)
+
+var (
+	_defaultRateLimitConfig = &RateLimitConfig{}
+)
+
+// Rate 限流规则，每 Period 时间内最多允许 Limit 次请求
+type Rate struct {
+	Limit  int
+	Period time.Duration
+	// Burst 令牌桶的容量，<= 0 时等于 Limit，滑动窗口忽略该选项
+	Burst int
+}
+
+// RateLimitResult 一次限流检查的结果
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset 配额完全恢复所需的时间
+	Reset time.Duration
+	// RetryAfter 被拒绝时距离下一次允许请求的时间
+	RetryAfter time.Duration
+}
+
+// RateLimiter 限流算法
+type RateLimiter interface {
+	// Take 为 key 消耗一次配额
+	Take(key string, rate *Rate) *RateLimitResult
+}
+
+// RateLimitConfig 限流中间件配置
+type RateLimitConfig struct {
+	// Limiter 限流算法，默认为内存令牌桶
+	Limiter RateLimiter
+	// Rate 默认的限流规则，为 nil 时只对调用了 Route.SetRateLimit 的路由限流
+	Rate *Rate
+	// KeyFunc 限流的 key，默认为客户端 IP，返回空字符串时使用客户端 IP
+	KeyFunc func(*Context) string
+}
+
+// RateLimit 限流中间件
+// 超过限制的请求返回 429，并设置 Retry-After 和 RateLimit-* 响应头。
+// 路由设置了 Route.SetRateLimit 时使用路由的规则，且配额只在该路由内共享；conf 为 nil 时使用默认配置
+func RateLimit(conf *RateLimitConfig) Handler {
+	if conf == nil {
+		conf = _defaultRateLimitConfig
+	}
+	limiter := conf.Limiter
+	if limiter == nil {
+		limiter = NewTokenBucket()
+	}
+	keyFunc := conf.KeyFunc
+	if keyFunc == nil {
+		keyFunc = KeyByClientIP
+	}
+	return func(ctx *Context) {
+		rate := conf.Rate
+		scope := ""
+		if ctx.route != nil {
+			if r := ctx.route.rateLimit(); r != nil {
+				rate, scope = r, ctx.route.name+"|"
+			}
+		}
+		if rate == nil || rate.Limit <= 0 || rate.Period <= 0 {
+			return
+		}
+		key := keyFunc(ctx)
+		if key == "" {
+			key = ctx.ClientIP()
+		}
+		res := limiter.Take(scope+key, rate)
+		header := ctx.Writer.Header()
+		header.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		header.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		header.Set("RateLimit-Reset", strconv.FormatInt(ceilSeconds(res.Reset), 10))
+		if !res.Allowed {
+			header.Set("Retry-After", strconv.FormatInt(ceilSeconds(res.RetryAfter), 10))
+			ctx.AbortWithError(http.StatusTooManyRequests, xerror.TooManyRequests)
+		}
+	}
+}
+
+// KeyByClientIP 以客户端 IP 作为限流 key
+func KeyByClientIP(ctx *Context) string {
+	return ctx.ClientIP()
+}
+
+// KeyByRouteName 以路由名称作为限流 key，即路由的所有请求共享配额
+func KeyByRouteName(ctx *Context) string {
+	return ctx.RouteName()
+}
+
+// KeyByHeader 以请求头作为限流 key，如 API key
+func KeyByHeader(name string) func(*Context) string {
+	return func(ctx *Context) string {
+		if v := ctx.Request.Header.Get(name); v != "" {
+			return name + ":" + v
+		}
+		return ""
+	}
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// limitEntry 单个 key 的限流状态
+type limitEntry struct {
+	// 令牌桶
+	tokens float64
+	last   time.Time
+	// 滑动窗口
+	start time.Time
+	prev  int
+	curr  int
+
+	expire time.Time
+}
+
+// limitStore 内存中的限流状态，定期清理过期 (空闲) 的 key
+type limitStore struct {
+	mu        sync.Mutex
+	entries   map[string]*limitEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func newLimitStore() limitStore {
+	return limitStore{
+		entries: make(map[string]*limitEntry),
+		now:     time.Now,
+	}
+}
+
+// entry 返回 key 的限流状态，调用方需持有锁
+func (s *limitStore) entry(key string, now time.Time) (e *limitEntry, ok bool) {
+	if now.Sub(s.lastSweep) >= _sweepInterval {
+		for k, e := range s.entries {
+			if now.After(e.expire) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	if e, ok = s.entries[key]; ok && now.After(e.expire) {
+		ok = false
+	}
+	if !ok {
+		e = &limitEntry{}
+		s.entries[key] = e
+	}
+	return
+}
+
+// Len 返回当前保存的 key 数量
+func (s *limitStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// TokenBucket 内存令牌桶限流
+// 令牌以 Limit/Period 的速率恢复，桶的容量为 Burst
+type TokenBucket struct {
+	limitStore
+}
+
+// NewTokenBucket 返回内存令牌桶限流器
+func NewTokenBucket() *TokenBucket {
+	return &TokenBucket{limitStore: newLimitStore()}
+}
+
+// Take 为 key 消耗一个令牌
+func (tb *TokenBucket) Take(key string, rate *Rate) *RateLimitResult {
+	capacity := float64(rate.Limit)
+	if rate.Burst > 0 {
+		capacity = float64(rate.Burst)
+	}
+	// 每秒恢复的令牌数
+	speed := float64(rate.Limit) / rate.Period.Seconds()
+	full := time.Duration(capacity / speed * float64(time.Second))
+
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	now := tb.now()
+	e, ok := tb.entry(key, now)
+	if !ok {
+		e.tokens = capacity
+	} else {
+		e.tokens = math.Min(capacity, e.tokens+now.Sub(e.last).Seconds()*speed)
+	}
+	e.last = now
+	e.expire = now.Add(full)
+
+	res := &RateLimitResult{Limit: int(capacity)}
+	if e.tokens >= 1 {
+		e.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - e.tokens) / speed * float64(time.Second))
+	}
+	res.Remaining = int(e.tokens)
+	res.Reset = time.Duration((capacity - e.tokens) / speed * float64(time.Second))
+	return res
+}
+
+// SlidingWindow 内存滑动窗口限流
+// 使用上一个窗口的计数按时间加权估算当前滑动窗口内的请求数
+type SlidingWindow struct {
+	limitStore
+}
+
+// NewSlidingWindow 返回内存滑动窗口限流器
+func NewSlidingWindow() *SlidingWindow {
+	return &SlidingWindow{limitStore: newLimitStore()}
+}
+
+// Take 为 key 记录一次请求
+func (sw *SlidingWindow) Take(key string, rate *Rate) *RateLimitResult {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	now := sw.now()
+	e, _ := sw.entry(key, now)
+	start := now.Truncate(rate.Period)
+	switch {
+	case e.start.Equal(start):
+	case e.start.Add(rate.Period).Equal(start):
+		e.prev, e.curr, e.start = e.curr, 0, start
+	default:
+		e.prev, e.curr, e.start = 0, 0, start
+	}
+	e.expire = start.Add(rate.Period * 2)
+
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(rate.Period)
+	estimated := float64(e.prev)*weight + float64(e.curr)
+	res := &RateLimitResult{
+		Limit: rate.Limit,
+		Reset: rate.Period - elapsed,
+	}
+	if estimated+1 <= float64(rate.Limit) {
+		e.curr++
+		res.Allowed = true
+		res.Remaining = int(float64(rate.Limit) - estimated - 1)
+		return res
+	}
+	// 当前窗口已满时需要等到下一个窗口，否则等到上一个窗口的权重足够小
+	res.RetryAfter = rate.Period - elapsed
+	if e.curr < rate.Limit && e.prev > 0 {
+		need := float64(e.prev+e.curr+1-rate.Limit) / float64(e.prev)
+		if wait := time.Duration(need*float64(rate.Period)) - elapsed; wait < res.RetryAfter {
+			res.RetryAfter = wait
+		}
+	}
+	return res
+}
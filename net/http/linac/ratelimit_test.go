@@ -0,0 +1,130 @@
+package linac
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func TestTokenBucket(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	tb := NewTokenBucket()
+	tb.now = clock.Now
+	rate := &Rate{Limit: 2, Period: time.Second, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		res := tb.Take("k", rate)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 2-i, res.Remaining)
+	}
+	res := tb.Take("k", rate)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+
+	clock.now = clock.now.Add(500 * time.Millisecond)
+	assert.True(t, tb.Take("k", rate).Allowed)
+	assert.False(t, tb.Take("k", rate).Allowed)
+
+	t.Run("Should expire idle keys", func(t *testing.T) {
+		clock.now = clock.now.Add(_sweepInterval)
+		tb.Take("other", rate)
+		assert.Equal(t, 1, tb.Len())
+	})
+}
+
+func TestSlidingWindow(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	sw := NewSlidingWindow()
+	sw.now = clock.Now
+	rate := &Rate{Limit: 4, Period: 10 * time.Second}
+
+	for i := 0; i < 4; i++ {
+		assert.True(t, sw.Take("k", rate).Allowed)
+	}
+	res := sw.Take("k", rate)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 10*time.Second, res.RetryAfter)
+
+	// 下一个窗口过去 5s，上一个窗口的权重为 0.5，估算为 2 个请求
+	clock.now = clock.now.Add(15 * time.Second)
+	assert.True(t, sw.Take("k", rate).Allowed)
+	res = sw.Take("k", rate)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	res = sw.Take("k", rate)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 2500*time.Millisecond, res.RetryAfter)
+}
+
+func TestRateLimit(t *testing.T) {
+	engine := NewEngine()
+	engine.Use(RateLimit(&RateLimitConfig{
+		Rate:    &Rate{Limit: 1, Period: time.Minute},
+		KeyFunc: KeyByHeader("X-API-Key"),
+	}))
+	engine.GET("/default", "ratelimit.default", func(ctx *Context) {
+		ctx.String(200, "ok")
+	})
+	engine.GET("/route", "ratelimit.route", func(ctx *Context) {
+		ctx.String(200, "ok")
+	}).SetRateLimit(&Rate{Limit: 2, Period: time.Minute})
+
+	do := func(path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/default", "a")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", w.Header().Get("RateLimit-Reset"))
+
+	w = do("/default", "a")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.Equal(t, "{\"code\":429,\"err\":\"429\",\"data\":null}", w.Body.String())
+
+	assert.Equal(t, http.StatusOK, do("/default", "b").Code)
+	assert.Equal(t, http.StatusOK, do("/route", "a").Code)
+	assert.Equal(t, http.StatusOK, do("/route", "a").Code)
+	assert.Equal(t, http.StatusTooManyRequests, do("/route", "a").Code)
+}
+
+func TestRateLimitDefaultConfig(t *testing.T) {
+	engine := NewEngine()
+	engine.Use(RateLimit(nil))
+	engine.GET("/open", "ratelimit.open", func(ctx *Context) {
+		ctx.String(200, "ok")
+	})
+	engine.GET("/limited", "ratelimit.limited", func(ctx *Context) {
+		ctx.String(200, "ok")
+	}).SetRateLimit(&Rate{Limit: 1, Period: time.Minute})
+	// 路由配置不会覆盖限流规则
+	route, _ := engine.GetRoute("ratelimit.limited")
+	route.SetConfig(&RouteConfig{MaxRequestBody: 1 << 10})
+
+	do := func(path string) int {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, do("/open"))
+	assert.Equal(t, http.StatusOK, do("/open"))
+	assert.Equal(t, http.StatusOK, do("/limited"))
+	assert.Equal(t, http.StatusTooManyRequests, do("/limited"))
+}
@@ -2,32 +2,100 @@ package linac
 
 import (
 	"fmt"
+	xerror "linac/error"
+	"linac/log"
 	"net/http"
 	"net/http/httputil"
-	"os"
 	"runtime"
+	"strings"
+)
+
+const (
+	// _stackSize 记录的 panic 调用栈最大字节数
+	_stackSize = 64 << 10
+	_redacted  = "[REDACTED]"
+)
 
-	"github.com/prometheus/common/log"
+var (
+	// _sensitiveHeaders 记录请求时隐藏的请求头
+	_sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-API-Key", "X-CSRF-Token", "X-Signature"}
+	// _sensitiveQuery 记录请求时隐藏的 query 参数
+	_sensitiveQuery = []string{"token", "access_token", "api_key", "password", "secret", "signature"}
 )
 
-// Recovery 从 panic 中恢复 server，并记录下 ctx
+// Recovery 从 panic 中恢复 server，记录日志并返回 500
 func Recovery() Handler {
-	return func(c *Context) {
+	return RecoveryWithHandler(nil)
+}
+
+// RecoveryWithHandler 从 panic 中恢复 server
+// 通过 linac/log 记录 panic 的值、调用栈以及隐藏了敏感信息的请求，统计 panic 指标后调用 handle 生成响应；
+// handle 为 nil 时返回 500 与 xerror.ServerErr。
+// http.ErrAbortHandler 表示 handler 主动中断响应，会继续 panic 交给 http.Server 处理
+func RecoveryWithHandler(handle func(ctx *Context, err interface{})) Handler {
+	if handle == nil {
+		handle = defaultRecoveryHandler
+	}
+	return func(ctx *Context) {
 		defer func() {
-			var rawReq []byte
-			if err := recover(); err != nil {
-				const size = 64 << 10
-				buf := make([]byte, size)
-				buf = buf[:runtime.Stack(buf, false)]
-				if c.Request != nil {
-					rawReq, _ = httputil.DumpRequest(c.Request, false)
-				}
-				pl := fmt.Sprintf("http call panic: %s\n%v\n%s\n", string(rawReq), err, buf)
-				fmt.Fprintf(os.Stderr, pl)
-				log.Error(pl)
-				c.Abort(http.StatusInternalServerError)
+			err := recover()
+			if err == nil {
+				return
 			}
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			buf := make([]byte, _stackSize)
+			buf = buf[:runtime.Stack(buf, false)]
+			log.Errorv(ctx,
+				log.KV("event", "panic"),
+				log.KV("route", ctx.RouteName()),
+				log.KV("error", fmt.Sprint(err)),
+				log.KV("request", dumpRequest(ctx.Request)),
+				log.KV("stack", string(buf)),
+			)
+			_metricPanics.Inc(ctx.RouteName())
+			handle(ctx, err)
 		}()
-		c.Next()
+		ctx.Next()
+	}
+}
+
+func defaultRecoveryHandler(ctx *Context, err interface{}) {
+	ctx.AbortWithError(http.StatusInternalServerError, xerror.ServerErr)
+}
+
+// dumpRequest 返回隐藏了敏感请求头与 query 参数的请求，不包含请求体
+func dumpRequest(req *http.Request) string {
+	if req == nil {
+		return ""
+	}
+	r := *req
+	r.Header = req.Header.Clone()
+	for _, h := range _sensitiveHeaders {
+		if r.Header.Get(h) != "" {
+			r.Header.Set(h, _redacted)
+		}
+	}
+	if req.URL != nil && req.URL.RawQuery != "" {
+		u := *req.URL
+		query := u.Query()
+		for k := range query {
+			for _, s := range _sensitiveQuery {
+				if strings.EqualFold(k, s) {
+					query.Set(k, _redacted)
+				}
+			}
+		}
+		u.RawQuery = query.Encode()
+		r.URL = &u
+		if r.RequestURI != "" {
+			r.RequestURI = u.RequestURI()
+		}
+	}
+	dump, err := httputil.DumpRequest(&r, false)
+	if err != nil {
+		return err.Error()
 	}
+	return string(dump)
 }
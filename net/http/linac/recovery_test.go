@@ -0,0 +1,68 @@
+package linac
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecovery(t *testing.T) {
+	t.Run("envelope", func(t *testing.T) {
+		engine := NewEngine()
+		engine.GET("/recovery-panic", "recovery.panic", func(ctx *Context) {
+			panic("%s%d boom")
+		})
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest("GET", "/recovery-panic", nil))
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":500`)
+	})
+
+	t.Run("custom handler", func(t *testing.T) {
+		engine := NewEngine()
+		var recovered interface{}
+		engine.Use(RecoveryWithHandler(func(ctx *Context, err interface{}) {
+			recovered = err
+			ctx.String(http.StatusServiceUnavailable, "recovered")
+		}))
+		engine.GET("/recovery-custom", "recovery.custom", func(ctx *Context) {
+			panic("boom")
+		})
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest("GET", "/recovery-custom", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "recovered", w.Body.String())
+		assert.Equal(t, "boom", recovered)
+	})
+
+	t.Run("abort handler", func(t *testing.T) {
+		engine := NewEngine()
+		engine.GET("/recovery-abort", "recovery.abort", func(ctx *Context) {
+			panic(http.ErrAbortHandler)
+		})
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/recovery-abort", nil))
+		})
+	})
+}
+
+func TestDumpRequest(t *testing.T) {
+	req := httptest.NewRequest("GET", "/dump?id=1&token=abc&Password=123", nil)
+	req.Header.Set("Authorization", "Bearer secret-token")
+	req.Header.Set("Cookie", "session=secret-session")
+	req.Header.Set("X-Custom", "visible")
+	dump := dumpRequest(req)
+	assert.NotContains(t, dump, "secret-token")
+	assert.NotContains(t, dump, "secret-session")
+	assert.NotContains(t, dump, "abc")
+	assert.NotContains(t, dump, "123")
+	assert.Contains(t, dump, "id=1")
+	assert.Contains(t, dump, "X-Custom: visible")
+	assert.Contains(t, dump, "token=%5BREDACTED%5D")
+	assert.Equal(t, 2, strings.Count(dump, _redacted))
+	assert.Equal(t, "Bearer secret-token", req.Header.Get("Authorization"))
+	assert.Equal(t, "", dumpRequest(nil))
+}
@@ -1,7 +1,12 @@
 package render
 
 import (
+	"bytes"
+	"encoding"
 	xjson "encoding/json"
+	"io"
+	"reflect"
+	"sort"
 )
 
 const (
@@ -22,6 +27,22 @@ func (j JSON) Render() (content []byte, err error) {
 	return
 }
 
+// WriteTo 将 json 直接编码到 w 中，输出与 Render 相同
+// Data 为 slice 或 array 时逐个元素编码并写入，内存占用只与单个元素的大小有关；
+// 编码出错时 w 中可能已经写入了部分内容
+func (j JSON) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	enc := newJSONStream(cw)
+	err := enc.writeCode(j.Code, j.Err)
+	if err == nil {
+		err = enc.writeValue(reflect.ValueOf(j.Data))
+	}
+	if err == nil {
+		_, err = io.WriteString(cw, "}")
+	}
+	return cw.n, err
+}
+
 // ContentType 返回 content type
 func (j JSON) ContentType() string {
 	return _contentJSON
@@ -36,7 +57,160 @@ func (j JSONMap) Render() (content []byte, err error) {
 	return
 }
 
+// WriteTo 将 json 直接编码到 w 中，输出与 Render 相同
+// 按 key 排序后逐个写入，值为 slice 或 array 时逐个元素编码
+func (j JSONMap) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	if j == nil {
+		_, err := io.WriteString(cw, "null")
+		return cw.n, err
+	}
+	keys := make([]string, 0, len(j))
+	for k := range j {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	enc := newJSONStream(cw)
+	sep := "{"
+	for _, k := range keys {
+		if _, err := io.WriteString(cw, sep); err != nil {
+			return cw.n, err
+		}
+		sep = ","
+		if err := enc.marshal(k); err != nil {
+			return cw.n, err
+		}
+		if _, err := io.WriteString(cw, ":"); err != nil {
+			return cw.n, err
+		}
+		if err := enc.writeValue(reflect.ValueOf(j[k])); err != nil {
+			return cw.n, err
+		}
+	}
+	if sep == "{" {
+		_, err := io.WriteString(cw, "{}")
+		return cw.n, err
+	}
+	_, err := io.WriteString(cw, "}")
+	return cw.n, err
+}
+
 // ContentType 返回 content type
 func (j JSONMap) ContentType() string {
 	return _contentJSON
 }
+
+var (
+	_jsonComma     = []byte(",")
+	_jsonMarshaler = reflect.TypeOf((*xjson.Marshaler)(nil)).Elem()
+	_textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// jsonStream 将 json 逐段写入 w，编码时复用同一个缓冲区
+type jsonStream struct {
+	w   io.Writer
+	buf bytes.Buffer
+	enc *xjson.Encoder
+}
+
+func newJSONStream(w io.Writer) *jsonStream {
+	s := &jsonStream{w: w}
+	s.enc = xjson.NewEncoder(&s.buf)
+	return s
+}
+
+// writeCode 写入 JSON 的 code 与 err 字段，以及 data 的 key
+func (s *jsonStream) writeCode(code int, msg string) error {
+	if _, err := io.WriteString(s.w, `{"code":`); err != nil {
+		return err
+	}
+	if err := s.marshal(code); err != nil {
+		return err
+	}
+	if msg != "" {
+		if _, err := io.WriteString(s.w, `,"err":`); err != nil {
+			return err
+		}
+		if err := s.marshal(msg); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(s.w, `,"data":`)
+	return err
+}
+
+// writeValue 将 v 编码到 w 中
+// slice 与 array 逐个元素写入，其他类型整体编码
+func (s *jsonStream) writeValue(v reflect.Value) error {
+	if !streamableJSON(v) {
+		switch {
+		case !v.IsValid():
+			return s.marshal(nil)
+		case v.CanAddr():
+			// 避免复制元素
+			return s.marshal(v.Addr().Interface())
+		}
+		return s.marshal(v.Interface())
+	}
+	if v.Kind() == reflect.Slice && v.IsNil() {
+		_, err := io.WriteString(s.w, "null")
+		return err
+	}
+	if _, err := io.WriteString(s.w, "["); err != nil {
+		return err
+	}
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			if _, err := s.w.Write(_jsonComma); err != nil {
+				return err
+			}
+		}
+		elem := v.Index(i)
+		switch {
+		case elem.Kind() == reflect.Interface:
+			elem = elem.Elem()
+		case elem.CanAddr() && customJSON(elem.Addr().Type()):
+			// encoding/json 对可寻址的元素使用指针接收者的 MarshalJSON
+			elem = elem.Addr()
+		}
+		if err := s.writeValue(elem); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(s.w, "]")
+	return err
+}
+
+// marshal 编码 v 并写入 w，输出与 json.Marshal 相同
+func (s *jsonStream) marshal(v interface{}) error {
+	s.buf.Reset()
+	if err := s.enc.Encode(v); err != nil {
+		return err
+	}
+	// 去掉 Encoder 添加的换行
+	_, err := s.w.Write(s.buf.Bytes()[:s.buf.Len()-1])
+	return err
+}
+
+// streamableJSON 是否可以逐个元素编码
+// []byte 编码为 base64，自定义了编码方式的类型整体编码
+func streamableJSON(v reflect.Value) bool {
+	if !v.IsValid() {
+		return false
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return false
+		}
+	case reflect.Array:
+	default:
+		return false
+	}
+	return !customJSON(v.Type())
+}
+
+// customJSON 类型是否自定义了 json 编码方式
+func customJSON(t reflect.Type) bool {
+	return t.Implements(_jsonMarshaler) || t.Implements(_textMarshaler)
+}
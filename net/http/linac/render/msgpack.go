@@ -0,0 +1,173 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+const (
+	// ContentMsgPack content-type
+	_contentMsgPack = "application/msgpack"
+
+	// _maxMsgPackDepth 数组、map 与指针的最大嵌套深度，与 encoding/json 相同，
+	// 避免深层嵌套的输入或循环引用耗尽 goroutine 栈
+	_maxMsgPackDepth = 10000
+)
+
+var errMsgPackDepth = errors.New("msgpack: exceeded max depth")
+
+// MsgPack 返回 msgpack 渲染，字段与 JSON 保持一致
+type MsgPack struct {
+	Code int         `msgpack:"code"`
+	Err  string      `msgpack:"err,omitempty"`
+	Data interface{} `msgpack:"data"`
+}
+
+// Render Render
+func (m MsgPack) Render() ([]byte, error) {
+	return MarshalMsgPack(m)
+}
+
+// WriteTo 将 msgpack 直接编码到 w 中
+func (m MsgPack) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	err := NewMsgPackEncoder(cw).Encode(m)
+	return cw.n, err
+}
+
+// ContentType 返回 content type
+func (m MsgPack) ContentType() string {
+	return _contentMsgPack
+}
+
+// MarshalMsgPack 将 v 编码为 msgpack
+// 结构体字段名优先取 msgpack tag，其次为 json tag，规则同 encoding/json：
+// `msgpack:"name,omitempty"`，`msgpack:"-"` 忽略该字段
+// 实现了 json.Marshaler 的类型 (如 json.RawMessage、json.Number) 按 MarshalJSON 的结果编码，与 JSON 渲染保持一致
+func MarshalMsgPack(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := NewMsgPackEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalMsgPack 将 msgpack 数据解码到 v 中，v 必须为非 nil 指针
+func UnmarshalMsgPack(data []byte, v interface{}) error {
+	return NewMsgPackDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// field 结构体字段信息
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+var _fieldCache sync.Map // NOTE: map[reflect.Type][]*field
+
+// cachedFields 返回结构体可编码的字段，匿名结构体字段将被展开
+func cachedFields(t reflect.Type) []*field {
+	if fs, ok := _fieldCache.Load(t); ok {
+		return fs.([]*field)
+	}
+	fs := typeFields(t, nil)
+	_fieldCache.Store(t, fs)
+	return fs
+}
+
+func typeFields(t reflect.Type, index []int) (fields []*field) {
+	names := make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("msgpack")
+		if !ok {
+			tag = sf.Tag.Get("json")
+		}
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if idx := strings.Index(tag, ","); idx != -1 {
+			name, opts = tag[:idx], tag[idx+1:]
+		}
+		fidx := make([]int, len(index)+1)
+		copy(fidx, index)
+		fidx[len(index)] = i
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			if sf.Type.Kind() == reflect.Ptr {
+				// 匿名结构体指针字段在 nil 时无法展开，这里不做支持
+				continue
+			}
+			for _, f := range typeFields(ft, fidx) {
+				if _, ok := names[f.name]; ok {
+					continue
+				}
+				names[f.name] = len(fields)
+				fields = append(fields, f)
+			}
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		f := &field{
+			name:      name,
+			index:     fidx,
+			omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+		}
+		// 外层字段覆盖匿名结构体中的同名字段
+		if i, ok := names[name]; ok {
+			fields[i] = f
+			continue
+		}
+		names[name] = len(fields)
+		fields = append(fields, f)
+	}
+	return
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// depth 编解码时的嵌套深度
+type depth int
+
+// enter 进入一层嵌套，超过 _maxMsgPackDepth 时返回错误
+func (d *depth) enter() error {
+	*d++
+	if *d > _maxMsgPackDepth {
+		return errMsgPackDepth
+	}
+	return nil
+}
+
+func (d *depth) leave() {
+	*d--
+}
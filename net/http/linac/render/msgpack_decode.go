@@ -0,0 +1,659 @@
+package render
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// _maxPrealloc 根据长度头预分配的最大元素数量，避免恶意的长度头占用大量内存
+const _maxPrealloc = 1024
+
+var (
+	_textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	_jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// MsgPackDecoder msgpack 解码器
+type MsgPackDecoder struct {
+	r     *bufio.Reader
+	buf   [8]byte
+	depth depth
+}
+
+// NewMsgPackDecoder 返回一个从 r 读取的解码器
+func NewMsgPackDecoder(r io.Reader) *MsgPackDecoder {
+	return &MsgPackDecoder{r: bufio.NewReader(r)}
+}
+
+// Decode 读取下一个 msgpack 值并解码到 v 中，v 必须为非 nil 指针
+// 数组与 map 嵌套超过 10000 层时返回错误
+func (d *MsgPackDecoder) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("msgpack: decode into non-pointer or nil %T", v)
+	}
+	c, err := d.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	d.depth = 0
+	return d.decode(c, rv.Elem())
+}
+
+func (d *MsgPackDecoder) decode(c byte, v reflect.Value) error {
+	if c == _mpNil {
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return nil
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decode(c, v.Elem())
+	}
+	if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
+		val, err := d.decodeAny(c)
+		if err != nil {
+			return err
+		}
+		if val == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(val))
+		}
+		return nil
+	}
+	if (v.Type() == _timeType || v.Type() == _extType) && isExt(c) {
+		val, err := d.decodeExt(c)
+		if err != nil {
+			return err
+		}
+		rv := reflect.ValueOf(val)
+		if rv.Type() != v.Type() {
+			return typeError("ext", v)
+		}
+		v.Set(rv)
+		return nil
+	}
+	if isStr(c) && v.CanAddr() && v.Addr().Type().Implements(_textUnmarshalerType) {
+		bs, err := d.readRaw(c)
+		if err != nil {
+			return err
+		}
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText(bs)
+	}
+	if v.Type() == _jsonNumberType && (isInt(c) || c == _mpFloat32 || c == _mpFloat64) {
+		val, err := d.decodeAny(c)
+		if err != nil {
+			return err
+		}
+		v.SetString(fmt.Sprint(val))
+		return nil
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(_jsonUnmarshalerType) {
+		// 如 json.RawMessage，先解码为通用类型，再转换为 JSON
+		val, err := d.decodeAny(c)
+		if err != nil {
+			return err
+		}
+		bs, err := json.Marshal(val)
+		if err != nil {
+			return err
+		}
+		return v.Addr().Interface().(json.Unmarshaler).UnmarshalJSON(bs)
+	}
+	switch {
+	case c == _mpTrue || c == _mpFalse:
+		if v.Kind() != reflect.Bool {
+			return typeError("bool", v)
+		}
+		v.SetBool(c == _mpTrue)
+		return nil
+	case isInt(c):
+		i, u, signed, err := d.readInt(c)
+		if err != nil {
+			return err
+		}
+		return setNumber(v, i, u, signed)
+	case c == _mpFloat32 || c == _mpFloat64:
+		f, err := d.readFloat(c)
+		if err != nil {
+			return err
+		}
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(f)
+			return nil
+		}
+		return typeError("float", v)
+	case isStr(c) || isBin(c):
+		bs, err := d.readRaw(c)
+		if err != nil {
+			return err
+		}
+		switch {
+		case v.Kind() == reflect.String:
+			v.SetString(string(bs))
+			return nil
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+			v.SetBytes(bs)
+			return nil
+		}
+		return typeError("string", v)
+	case isArray(c):
+		n, err := d.containerLen(c)
+		if err != nil {
+			return err
+		}
+		if err := d.depth.enter(); err != nil {
+			return err
+		}
+		defer d.depth.leave()
+		return d.decodeArray(n, v)
+	case isMap(c):
+		n, err := d.containerLen(c)
+		if err != nil {
+			return err
+		}
+		if err := d.depth.enter(); err != nil {
+			return err
+		}
+		defer d.depth.leave()
+		switch v.Kind() {
+		case reflect.Map:
+			return d.decodeMap(n, v)
+		case reflect.Struct:
+			return d.decodeStruct(n, v)
+		}
+		return typeError("map", v)
+	case isExt(c):
+		return typeError("ext", v)
+	}
+	return fmt.Errorf("msgpack: invalid code 0x%x", c)
+}
+
+// decodeAny 解码为通用类型
+// 整数解码为 int64 (超出范围时为 uint64)，浮点数解码为 float64，
+// map 的 key 均为字符串时解码为 map[string]interface{}，否则为 map[interface{}]interface{}，
+// 时间戳解码为 time.Time，其他扩展类型解码为 MsgPackExt
+func (d *MsgPackDecoder) decodeAny(c byte) (interface{}, error) {
+	switch {
+	case c == _mpNil:
+		return nil, nil
+	case c == _mpTrue || c == _mpFalse:
+		return c == _mpTrue, nil
+	case isInt(c):
+		i, u, signed, err := d.readInt(c)
+		if err != nil {
+			return nil, err
+		}
+		if !signed && u > math.MaxInt64 {
+			return u, nil
+		}
+		if !signed {
+			i = int64(u)
+		}
+		return i, nil
+	case c == _mpFloat32 || c == _mpFloat64:
+		return d.readFloat(c)
+	case isStr(c):
+		bs, err := d.readRaw(c)
+		return string(bs), err
+	case isBin(c):
+		return d.readRaw(c)
+	case isArray(c):
+		n, err := d.containerLen(c)
+		if err != nil {
+			return nil, err
+		}
+		if err := d.depth.enter(); err != nil {
+			return nil, err
+		}
+		defer d.depth.leave()
+		arr := make([]interface{}, 0, prealloc(n))
+		for i := 0; i < n; i++ {
+			c, err := d.r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			val, err := d.decodeAny(c)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, val)
+		}
+		return arr, nil
+	case isMap(c):
+		n, err := d.containerLen(c)
+		if err != nil {
+			return nil, err
+		}
+		if err := d.depth.enter(); err != nil {
+			return nil, err
+		}
+		defer d.depth.leave()
+		keys := make([]interface{}, 0, prealloc(n))
+		values := make([]interface{}, 0, prealloc(n))
+		strKey := true
+		for i := 0; i < n*2; i++ {
+			c, err := d.r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			val, err := d.decodeAny(c)
+			if err != nil {
+				return nil, err
+			}
+			if i%2 == 1 {
+				values = append(values, val)
+				continue
+			}
+			if _, ok := val.(string); !ok {
+				strKey = false
+			}
+			keys = append(keys, val)
+		}
+		if strKey {
+			m := make(map[string]interface{}, len(keys))
+			for i, k := range keys {
+				m[k.(string)] = values[i]
+			}
+			return m, nil
+		}
+		m := make(map[interface{}]interface{}, len(keys))
+		for i, k := range keys {
+			if k != nil && !reflect.TypeOf(k).Comparable() {
+				return nil, fmt.Errorf("msgpack: unhashable map key %T", k)
+			}
+			m[k] = values[i]
+		}
+		return m, nil
+	case isExt(c):
+		return d.decodeExt(c)
+	}
+	return nil, fmt.Errorf("msgpack: invalid code 0x%x", c)
+}
+
+func (d *MsgPackDecoder) decodeArray(n int, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Slice:
+		slice := reflect.MakeSlice(v.Type(), 0, prealloc(n))
+		for i := 0; i < n; i++ {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := d.decodeNext(elem); err != nil {
+				return err
+			}
+			slice = reflect.Append(slice, elem)
+		}
+		v.Set(slice)
+		return nil
+	case reflect.Array:
+		for i := 0; i < n; i++ {
+			if i >= v.Len() {
+				if err := d.skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.decodeNext(v.Index(i)); err != nil {
+				return err
+			}
+		}
+		for i := n; i < v.Len(); i++ {
+			v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+		}
+		return nil
+	}
+	return typeError("array", v)
+}
+
+func (d *MsgPackDecoder) decodeMap(n int, v reflect.Value) error {
+	t := v.Type()
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(t, prealloc(n)))
+	}
+	for i := 0; i < n; i++ {
+		key := reflect.New(t.Key()).Elem()
+		if err := d.decodeNext(key); err != nil {
+			return err
+		}
+		if !hashable(key) {
+			return fmt.Errorf("msgpack: unhashable map key %s", key.Type())
+		}
+		elem := reflect.New(t.Elem()).Elem()
+		if err := d.decodeNext(elem); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+	}
+	return nil
+}
+
+func (d *MsgPackDecoder) decodeStruct(n int, v reflect.Value) error {
+	fields := cachedFields(v.Type())
+	for i := 0; i < n; i++ {
+		var name string
+		if err := d.decodeNext(reflect.ValueOf(&name).Elem()); err != nil {
+			return err
+		}
+		f := lookupField(fields, name)
+		if f == nil {
+			if err := d.skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.decodeNext(v.FieldByIndex(f.index)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookupField 查找字段，优先精确匹配，其次忽略大小写匹配
+func lookupField(fields []*field, name string) *field {
+	for _, f := range fields {
+		if f.name == name {
+			return f
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, name) {
+			return f
+		}
+	}
+	return nil
+}
+
+func (d *MsgPackDecoder) decodeNext(v reflect.Value) error {
+	c, err := d.r.ReadByte()
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	return d.decode(c, v)
+}
+
+func (d *MsgPackDecoder) skip() error {
+	c, err := d.r.ReadByte()
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	_, err = d.decodeAny(c)
+	return err
+}
+
+func (d *MsgPackDecoder) readFull(n int) ([]byte, error) {
+	if _, err := io.ReadFull(d.r, d.buf[:n]); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return d.buf[:n], nil
+}
+
+// readRaw 读取 str、bin 类型的内容
+func (d *MsgPackDecoder) readRaw(c byte) ([]byte, error) {
+	n, err := d.rawLen(c)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.Grow(prealloc(n))
+	if _, err := io.CopyN(&buf, d.r, int64(n)); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (d *MsgPackDecoder) readUint(n int) (uint64, error) {
+	bs, err := d.readFull(n)
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 1:
+		return uint64(bs[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(bs)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(bs)), nil
+	}
+	return binary.BigEndian.Uint64(bs), nil
+}
+
+// readLen 读取 n 字节的长度头
+func (d *MsgPackDecoder) readLen(n int) (int, error) {
+	l, err := d.readUint(n)
+	return int(l), err
+}
+
+func (d *MsgPackDecoder) readInt(c byte) (i int64, u uint64, signed bool, err error) {
+	switch {
+	case c <= 0x7f:
+		return 0, uint64(c), false, nil
+	case c >= 0xe0:
+		return int64(int8(c)), 0, true, nil
+	case c >= _mpUint8 && c <= _mpUint64:
+		u, err = d.readUint(1 << (c - _mpUint8))
+		return 0, u, false, err
+	}
+	n := 1 << (c - _mpInt8)
+	u, err = d.readUint(n)
+	switch n {
+	case 1:
+		i = int64(int8(u))
+	case 2:
+		i = int64(int16(u))
+	case 4:
+		i = int64(int32(u))
+	default:
+		i = int64(u)
+	}
+	return i, 0, true, err
+}
+
+func (d *MsgPackDecoder) readFloat(c byte) (float64, error) {
+	if c == _mpFloat32 {
+		u, err := d.readUint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	}
+	u, err := d.readUint(8)
+	return math.Float64frombits(u), err
+}
+
+// decodeExt 读取扩展类型，时间戳返回 time.Time，其他类型返回 MsgPackExt
+func (d *MsgPackDecoder) decodeExt(c byte) (interface{}, error) {
+	n, err := d.extLen(c)
+	if err != nil {
+		return nil, err
+	}
+	typ, err := d.r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if typ == _mpExtTimestamp {
+		return d.readTime(n)
+	}
+	var buf bytes.Buffer
+	buf.Grow(prealloc(n))
+	if _, err := io.CopyN(&buf, d.r, int64(n)); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return MsgPackExt{Type: int8(typ), Data: buf.Bytes()}, nil
+}
+
+// readTime 读取长度为 n 的时间戳扩展类型的数据
+func (d *MsgPackDecoder) readTime(n int) (t time.Time, err error) {
+	switch n {
+	case 4:
+		sec, err := d.readUint(4)
+		return time.Unix(int64(sec), 0), err
+	case 8:
+		u, err := d.readUint(8)
+		return time.Unix(int64(u&(1<<34-1)), int64(u>>34)), err
+	case 12:
+		nsec, err := d.readUint(4)
+		if err != nil {
+			return t, err
+		}
+		sec, err := d.readUint(8)
+		return time.Unix(int64(sec), int64(nsec)), err
+	}
+	return t, fmt.Errorf("msgpack: invalid timestamp length %d", n)
+}
+
+// extLen 读取扩展类型数据的长度
+func (d *MsgPackDecoder) extLen(c byte) (int, error) {
+	switch c {
+	case _mpExt8:
+		return d.readLen(1)
+	case _mpExt16:
+		return d.readLen(2)
+	case _mpExt32:
+		return d.readLen(4)
+	}
+	return 1 << (c - _mpFixExt1), nil
+}
+
+// rawLen 读取 str、bin 类型的长度
+func (d *MsgPackDecoder) rawLen(c byte) (int, error) {
+	switch c {
+	case _mpStr8, _mpBin8:
+		return d.readLen(1)
+	case _mpStr16, _mpBin16:
+		return d.readLen(2)
+	case _mpStr32, _mpBin32:
+		return d.readLen(4)
+	}
+	return int(c & 0x1f), nil
+}
+
+// containerLen 读取 array、map 类型的长度
+func (d *MsgPackDecoder) containerLen(c byte) (int, error) {
+	switch c {
+	case _mpArray16, _mpMap16:
+		return d.readLen(2)
+	case _mpArray32, _mpMap32:
+		return d.readLen(4)
+	}
+	return int(c & 0x0f), nil
+}
+
+func setNumber(v reflect.Value, i int64, u uint64, signed bool) error {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !signed {
+			if u > math.MaxInt64 {
+				return overflowError(u, v)
+			}
+			i = int64(u)
+		}
+		if v.OverflowInt(i) {
+			return overflowError(i, v)
+		}
+		v.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if signed {
+			if i < 0 {
+				return overflowError(i, v)
+			}
+			u = uint64(i)
+		}
+		if v.OverflowUint(u) {
+			return overflowError(u, v)
+		}
+		v.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		if signed {
+			v.SetFloat(float64(i))
+		} else {
+			v.SetFloat(float64(u))
+		}
+		return nil
+	}
+	return typeError("integer", v)
+}
+
+func isInt(c byte) bool {
+	return c <= 0x7f || c >= 0xe0 || (c >= _mpUint8 && c <= _mpInt64)
+}
+
+func isStr(c byte) bool {
+	return c&0xe0 == _mpFixStr || (c >= _mpStr8 && c <= _mpStr32)
+}
+
+func isBin(c byte) bool {
+	return c >= _mpBin8 && c <= _mpBin32
+}
+
+func isArray(c byte) bool {
+	return c&0xf0 == _mpFixArray || c == _mpArray16 || c == _mpArray32
+}
+
+func isMap(c byte) bool {
+	return c&0xf0 == _mpFixMap || c == _mpMap16 || c == _mpMap32
+}
+
+func isExt(c byte) bool {
+	return (c >= _mpExt8 && c <= _mpExt32) || (c >= _mpFixExt1 && c <= _mpFixExt16)
+}
+
+// hashable 判断 v 能否作为 map 的 key，interface 的动态类型为 slice、map 等时不能
+func hashable(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Interface:
+		return v.IsNil() || hashable(v.Elem())
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !hashable(v.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !hashable(v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	return v.Type().Comparable()
+}
+
+func prealloc(n int) int {
+	if n < 0 {
+		return 0
+	}
+	if n > _maxPrealloc {
+		return _maxPrealloc
+	}
+	return n
+}
+
+func typeError(src string, v reflect.Value) error {
+	return fmt.Errorf("msgpack: cannot decode %s into %s", src, v.Type())
+}
+
+func overflowError(n interface{}, v reflect.Value) error {
+	return fmt.Errorf("msgpack: %v overflows %s", n, v.Type())
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
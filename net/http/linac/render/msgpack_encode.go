@@ -0,0 +1,435 @@
+package render
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// msgpack 格式类型标识
+const (
+	_mpNil      = 0xc0
+	_mpFalse    = 0xc2
+	_mpTrue     = 0xc3
+	_mpBin8     = 0xc4
+	_mpBin16    = 0xc5
+	_mpBin32    = 0xc6
+	_mpExt8     = 0xc7
+	_mpExt16    = 0xc8
+	_mpExt32    = 0xc9
+	_mpFloat32  = 0xca
+	_mpFloat64  = 0xcb
+	_mpUint8    = 0xcc
+	_mpUint16   = 0xcd
+	_mpUint32   = 0xce
+	_mpUint64   = 0xcf
+	_mpInt8     = 0xd0
+	_mpInt16    = 0xd1
+	_mpInt32    = 0xd2
+	_mpInt64    = 0xd3
+	_mpFixExt1  = 0xd4
+	_mpFixExt2  = 0xd5
+	_mpFixExt4  = 0xd6
+	_mpFixExt8  = 0xd7
+	_mpFixExt16 = 0xd8
+	_mpStr8     = 0xd9
+	_mpStr16    = 0xda
+	_mpStr32    = 0xdb
+	_mpArray16  = 0xdc
+	_mpArray32  = 0xdd
+	_mpMap16    = 0xde
+	_mpMap32    = 0xdf
+
+	_mpFixMap   = 0x80
+	_mpFixArray = 0x90
+	_mpFixStr   = 0xa0
+
+	// 时间戳扩展类型 (-1)
+	_mpExtTimestamp = 0xff
+)
+
+var (
+	_timeType          = reflect.TypeOf(time.Time{})
+	_extType           = reflect.TypeOf(MsgPackExt{})
+	_jsonNumberType    = reflect.TypeOf(json.Number(""))
+	_textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	_jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// MsgPackExt 时间戳以外的 msgpack 扩展类型，解码到 interface{} 时返回该类型
+type MsgPackExt struct {
+	Type int8
+	Data []byte
+}
+
+// MsgPackEncoder msgpack 编码器
+type MsgPackEncoder struct {
+	w     *bufio.Writer
+	buf   [9]byte
+	depth depth
+}
+
+// NewMsgPackEncoder 返回一个写入 w 的编码器
+func NewMsgPackEncoder(w io.Writer) *MsgPackEncoder {
+	return &MsgPackEncoder{w: bufio.NewWriter(w)}
+}
+
+// Encode 将 v 编码写入
+// 嵌套超过 10000 层 (包括循环引用) 时返回错误
+func (e *MsgPackEncoder) Encode(v interface{}) error {
+	e.depth = 0
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+func (e *MsgPackEncoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		return e.w.WriteByte(_mpNil)
+	}
+	if v.Type() == _timeType {
+		return e.encodeTime(v.Interface().(time.Time))
+	}
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return e.w.WriteByte(_mpNil)
+	}
+	if v.Kind() == reflect.Ptr && v.Type().Elem() == _timeType {
+		return e.encode(v.Elem())
+	}
+	switch v.Type() {
+	case _extType:
+		return e.encodeExt(v.Interface().(MsgPackExt))
+	case _jsonNumberType:
+		return e.encodeJSONNumber(json.Number(v.String()))
+	}
+	if m, ok := jsonMarshaler(v); ok {
+		return e.encodeJSON(m)
+	}
+	if v.Type().Implements(_textMarshalerType) && v.Kind() != reflect.Interface {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return err
+		}
+		return e.encodeString(string(text))
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if err := e.depth.enter(); err != nil {
+			return err
+		}
+		defer e.depth.leave()
+		return e.encode(v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			return e.w.WriteByte(_mpTrue)
+		}
+		return e.w.WriteByte(_mpFalse)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return e.encodeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return e.encodeUint(v.Uint())
+	case reflect.Float32:
+		e.buf[0] = _mpFloat32
+		binary.BigEndian.PutUint32(e.buf[1:], math.Float32bits(float32(v.Float())))
+		return e.write(5)
+	case reflect.Float64:
+		e.buf[0] = _mpFloat64
+		binary.BigEndian.PutUint64(e.buf[1:], math.Float64bits(v.Float()))
+		return e.write(9)
+	case reflect.String:
+		return e.encodeString(v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			return e.w.WriteByte(_mpNil)
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return e.encodeBytes(v.Bytes())
+		}
+		return e.encodeArray(v)
+	case reflect.Array:
+		return e.encodeArray(v)
+	case reflect.Map:
+		if v.IsNil() {
+			return e.w.WriteByte(_mpNil)
+		}
+		return e.encodeMap(v)
+	case reflect.Struct:
+		return e.encodeStruct(v)
+	}
+	return fmt.Errorf("msgpack: unsupported type %s", v.Type())
+}
+
+func (e *MsgPackEncoder) write(n int) error {
+	_, err := e.w.Write(e.buf[:n])
+	return err
+}
+
+func (e *MsgPackEncoder) encodeInt(i int64) error {
+	switch {
+	case i >= 0:
+		return e.encodeUint(uint64(i))
+	case i >= -32:
+		return e.w.WriteByte(byte(int8(i)))
+	case i >= math.MinInt8:
+		e.buf[0], e.buf[1] = _mpInt8, byte(int8(i))
+		return e.write(2)
+	case i >= math.MinInt16:
+		e.buf[0] = _mpInt16
+		binary.BigEndian.PutUint16(e.buf[1:], uint16(i))
+		return e.write(3)
+	case i >= math.MinInt32:
+		e.buf[0] = _mpInt32
+		binary.BigEndian.PutUint32(e.buf[1:], uint32(i))
+		return e.write(5)
+	}
+	e.buf[0] = _mpInt64
+	binary.BigEndian.PutUint64(e.buf[1:], uint64(i))
+	return e.write(9)
+}
+
+func (e *MsgPackEncoder) encodeUint(u uint64) error {
+	switch {
+	case u <= 0x7f:
+		return e.w.WriteByte(byte(u))
+	case u <= math.MaxUint8:
+		e.buf[0], e.buf[1] = _mpUint8, byte(u)
+		return e.write(2)
+	case u <= math.MaxUint16:
+		e.buf[0] = _mpUint16
+		binary.BigEndian.PutUint16(e.buf[1:], uint16(u))
+		return e.write(3)
+	case u <= math.MaxUint32:
+		e.buf[0] = _mpUint32
+		binary.BigEndian.PutUint32(e.buf[1:], uint32(u))
+		return e.write(5)
+	}
+	e.buf[0] = _mpUint64
+	binary.BigEndian.PutUint64(e.buf[1:], u)
+	return e.write(9)
+}
+
+// encodeLen 写入长度头，fix 为 fix 类型的标识，max 为 fix 类型可容纳的最大长度
+func (e *MsgPackEncoder) encodeLen(n int, fix byte, max int, c8, c16, c32 byte) error {
+	switch {
+	case n <= max:
+		return e.w.WriteByte(fix | byte(n))
+	case c8 != 0 && n <= math.MaxUint8:
+		e.buf[0], e.buf[1] = c8, byte(n)
+		return e.write(2)
+	case n <= math.MaxUint16:
+		e.buf[0] = c16
+		binary.BigEndian.PutUint16(e.buf[1:], uint16(n))
+		return e.write(3)
+	case uint64(n) <= math.MaxUint32:
+		e.buf[0] = c32
+		binary.BigEndian.PutUint32(e.buf[1:], uint32(n))
+		return e.write(5)
+	}
+	return fmt.Errorf("msgpack: length %d overflow", n)
+}
+
+func (e *MsgPackEncoder) encodeString(s string) error {
+	if err := e.encodeLen(len(s), _mpFixStr, 31, _mpStr8, _mpStr16, _mpStr32); err != nil {
+		return err
+	}
+	_, err := e.w.WriteString(s)
+	return err
+}
+
+func (e *MsgPackEncoder) encodeBytes(bs []byte) error {
+	// bin 类型没有 fix 格式，这里用 -1 跳过
+	if err := e.encodeLen(len(bs), 0, -1, _mpBin8, _mpBin16, _mpBin32); err != nil {
+		return err
+	}
+	_, err := e.w.Write(bs)
+	return err
+}
+
+func (e *MsgPackEncoder) encodeArray(v reflect.Value) error {
+	if err := e.depth.enter(); err != nil {
+		return err
+	}
+	defer e.depth.leave()
+	if err := e.encodeLen(v.Len(), _mpFixArray, 15, 0, _mpArray16, _mpArray32); err != nil {
+		return err
+	}
+	for i := 0; i < v.Len(); i++ {
+		if err := e.encode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *MsgPackEncoder) encodeMap(v reflect.Value) error {
+	if err := e.depth.enter(); err != nil {
+		return err
+	}
+	defer e.depth.leave()
+	if err := e.encodeLen(v.Len(), _mpFixMap, 15, 0, _mpMap16, _mpMap32); err != nil {
+		return err
+	}
+	keys := v.MapKeys()
+	// 字符串 key 排序，保证输出稳定
+	if v.Type().Key().Kind() == reflect.String {
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	}
+	for _, k := range keys {
+		if err := e.encode(k); err != nil {
+			return err
+		}
+		if err := e.encode(v.MapIndex(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *MsgPackEncoder) encodeStruct(v reflect.Value) error {
+	if err := e.depth.enter(); err != nil {
+		return err
+	}
+	defer e.depth.leave()
+	fields := cachedFields(v.Type())
+	values := make([]reflect.Value, 0, len(fields))
+	names := make([]string, 0, len(fields))
+	for _, f := range fields {
+		fv := v.FieldByIndex(f.index)
+		if f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		names = append(names, f.name)
+		values = append(values, fv)
+	}
+	if err := e.encodeLen(len(values), _mpFixMap, 15, 0, _mpMap16, _mpMap32); err != nil {
+		return err
+	}
+	for i, fv := range values {
+		if err := e.encodeString(names[i]); err != nil {
+			return err
+		}
+		if err := e.encode(fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonMarshaler 返回 v 实现的 json.Marshaler，指针接收者的实现只在 v 可以取地址时使用
+func jsonMarshaler(v reflect.Value) (json.Marshaler, bool) {
+	if v.Kind() == reflect.Interface {
+		return nil, false
+	}
+	if v.Type().Implements(_jsonMarshalerType) {
+		m, ok := v.Interface().(json.Marshaler)
+		return m, ok
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(_jsonMarshalerType) {
+		return v.Addr().Interface().(json.Marshaler), true
+	}
+	return nil, false
+}
+
+// encodeJSON 将 MarshalJSON 的结果 (如 json.RawMessage) 解析后按 msgpack 编码，与 JSON 输出的结构一致
+func (e *MsgPackEncoder) encodeJSON(m json.Marshaler) error {
+	bs, err := m.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(bs))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	if err := e.depth.enter(); err != nil {
+		return err
+	}
+	defer e.depth.leave()
+	return e.encode(reflect.ValueOf(v))
+}
+
+// encodeJSONNumber 按整数或浮点数编码 json.Number
+func (e *MsgPackEncoder) encodeJSONNumber(n json.Number) error {
+	if i, err := n.Int64(); err == nil {
+		return e.encodeInt(i)
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		return e.encodeUint(u)
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return fmt.Errorf("msgpack: invalid number %q", n)
+	}
+	e.buf[0] = _mpFloat64
+	binary.BigEndian.PutUint64(e.buf[1:], math.Float64bits(f))
+	return e.write(9)
+}
+
+// encodeExt 编码扩展类型，数据长度为 1、2、4、8、16 时使用 fixext
+func (e *MsgPackEncoder) encodeExt(ext MsgPackExt) (err error) {
+	switch len(ext.Data) {
+	case 1:
+		err = e.w.WriteByte(_mpFixExt1)
+	case 2:
+		err = e.w.WriteByte(_mpFixExt2)
+	case 4:
+		err = e.w.WriteByte(_mpFixExt4)
+	case 8:
+		err = e.w.WriteByte(_mpFixExt8)
+	case 16:
+		err = e.w.WriteByte(_mpFixExt16)
+	default:
+		// ext 类型没有 fix 格式的长度头，这里用 -1 跳过
+		err = e.encodeLen(len(ext.Data), 0, -1, _mpExt8, _mpExt16, _mpExt32)
+	}
+	if err != nil {
+		return err
+	}
+	if err = e.w.WriteByte(byte(ext.Type)); err != nil {
+		return err
+	}
+	_, err = e.w.Write(ext.Data)
+	return err
+}
+
+// encodeTime 使用 msgpack 时间戳扩展类型编码
+func (e *MsgPackEncoder) encodeTime(t time.Time) error {
+	sec, nsec := t.Unix(), int64(t.Nanosecond())
+	switch {
+	case sec>>32 == 0 && nsec == 0:
+		e.buf[0], e.buf[1] = _mpFixExt4, _mpExtTimestamp
+		if err := e.write(2); err != nil {
+			return err
+		}
+		binary.BigEndian.PutUint32(e.buf[:], uint32(sec))
+		return e.write(4)
+	case sec>>34 == 0:
+		e.buf[0], e.buf[1] = _mpFixExt8, _mpExtTimestamp
+		if err := e.write(2); err != nil {
+			return err
+		}
+		binary.BigEndian.PutUint64(e.buf[:], uint64(nsec)<<34|uint64(sec))
+		return e.write(8)
+	}
+	e.buf[0], e.buf[1], e.buf[2] = _mpExt8, 12, _mpExtTimestamp
+	if err := e.write(3); err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint32(e.buf[:], uint32(nsec))
+	if err := e.write(4); err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint64(e.buf[:], uint64(sec))
+	return e.write(8)
+}
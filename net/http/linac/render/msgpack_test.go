@@ -0,0 +1,266 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mpInner struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+type mpEmbed struct {
+	Embedded string
+	Shadowed string
+}
+
+type mpStruct struct {
+	mpEmbed
+	Shadowed  string            `msgpack:"shadowed"`
+	Bool      bool              `msgpack:"bool"`
+	Int       int               `msgpack:"int"`
+	Int8      int8              `msgpack:"int8"`
+	Int16     int16             `msgpack:"int16"`
+	Int32     int32             `msgpack:"int32"`
+	Int64     int64             `msgpack:"int64"`
+	Uint      uint              `msgpack:"uint"`
+	Uint8     uint8             `msgpack:"uint8"`
+	Uint16    uint16            `msgpack:"uint16"`
+	Uint32    uint32            `msgpack:"uint32"`
+	Uint64    uint64            `msgpack:"uint64"`
+	Float32   float32           `msgpack:"float32"`
+	Float64   float64           `msgpack:"float64"`
+	String    string            `msgpack:"string"`
+	Bytes     []byte            `msgpack:"bytes"`
+	Slice     []string          `msgpack:"slice"`
+	Array     [3]int            `msgpack:"array"`
+	Map       map[string]int    `msgpack:"map"`
+	IntMap    map[int]string    `msgpack:"int_map"`
+	Ptr       *mpInner          `msgpack:"ptr"`
+	NilPtr    *mpInner          `msgpack:"nil_ptr"`
+	Structs   []mpInner         `msgpack:"structs"`
+	Time      time.Time         `msgpack:"time"`
+	IP        net.IP            `msgpack:"ip"`
+	Any       interface{}       `msgpack:"any"`
+	Omit      string            `msgpack:"omit,omitempty"`
+	Ignored   string            `msgpack:"-"`
+	JSONTag   string            `json:"json_tag"`
+	Nested    map[string][]bool `msgpack:"nested"`
+	unexposed int
+}
+
+func TestMsgPackRoundTrip(t *testing.T) {
+	t.Run("Should round trip struct", func(t *testing.T) {
+		in := mpStruct{
+			mpEmbed:  mpEmbed{Embedded: "embedded", Shadowed: "inner"},
+			Shadowed: "outer",
+			Bool:     true,
+			Int:      -1 << 40,
+			Int8:     math.MinInt8,
+			Int16:    math.MinInt16,
+			Int32:    math.MinInt32,
+			Int64:    math.MinInt64,
+			Uint:     1 << 40,
+			Uint8:    math.MaxUint8,
+			Uint16:   math.MaxUint16,
+			Uint32:   math.MaxUint32,
+			Uint64:   math.MaxUint64,
+			Float32:  1.5,
+			Float64:  math.Pi,
+			String:   strings.Repeat("s", 70000),
+			Bytes:    []byte{0, 1, 2},
+			Slice:    []string{"a", "b"},
+			Array:    [3]int{1, 2, 3},
+			Map:      map[string]int{"a": 1, "b": -200},
+			IntMap:   map[int]string{1: "one", -1: "minus one"},
+			Ptr:      &mpInner{ID: 7, Name: "seven"},
+			Structs:  []mpInner{{ID: 1}, {ID: 2, Name: "two"}},
+			Time:     time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC),
+			IP:       net.ParseIP("10.0.0.1"),
+			Any:      map[string]interface{}{"k": []interface{}{int64(1), "v", nil, true, 2.5}},
+			Ignored:  "ignored",
+			JSONTag:  "json",
+			Nested:   map[string][]bool{"x": {true, false}},
+		}
+		bs, err := MarshalMsgPack(in)
+		assert.Nil(t, err)
+		var out mpStruct
+		assert.Nil(t, UnmarshalMsgPack(bs, &out))
+		assert.True(t, in.Time.Equal(out.Time))
+		out.Time = in.Time
+		in.Ignored = ""
+		assert.Equal(t, in, out)
+	})
+
+	t.Run("Should round trip the render envelope", func(t *testing.T) {
+		values := []interface{}{
+			nil,
+			true,
+			false,
+			int64(0),
+			int64(127),
+			int64(-32),
+			int64(-33),
+			int64(math.MaxInt64),
+			int64(math.MinInt64),
+			uint64(math.MaxUint64),
+			2.5,
+			"",
+			strings.Repeat("x", 31),
+			strings.Repeat("x", 255),
+			strings.Repeat("x", 1<<16),
+			[]byte(strings.Repeat("b", 300)),
+			[]interface{}{},
+			make([]interface{}, 20),
+			map[string]interface{}{},
+			map[string]interface{}{"a": "b", "c": []interface{}{int64(1)}},
+			map[interface{}]interface{}{int64(1): "one"},
+			time.Unix(1<<35, 1).UTC(),
+			time.Unix(1<<33, 0).UTC(),
+			time.Unix(1<<32-1, 0).UTC(),
+			MsgPackExt{Type: 5, Data: []byte{1, 2, 3}},
+			MsgPackExt{Type: -2, Data: []byte(strings.Repeat("e", 300))},
+		}
+		for _, v := range values {
+			var buf bytes.Buffer
+			assert.Nil(t, Write(MsgPack{Code: -500, Err: "err", Data: v}, &buf))
+			var out MsgPack
+			assert.Nil(t, UnmarshalMsgPack(buf.Bytes(), &out))
+			assert.Equal(t, -500, out.Code)
+			assert.Equal(t, "err", out.Err)
+			if tm, ok := v.(time.Time); ok {
+				assert.True(t, tm.Equal(out.Data.(time.Time)))
+				continue
+			}
+			assert.Equal(t, v, out.Data)
+		}
+	})
+
+	t.Run("Should decode numbers into compatible kinds", func(t *testing.T) {
+		bs, err := MarshalMsgPack(map[string]interface{}{"id": 300, "name": "n", "extra": []int{1}})
+		assert.Nil(t, err)
+		var inner mpInner
+		assert.Nil(t, UnmarshalMsgPack(bs, &inner))
+		assert.Equal(t, mpInner{ID: 300, Name: "n"}, inner)
+		var f float64
+		bs, _ = MarshalMsgPack(-3)
+		assert.Nil(t, UnmarshalMsgPack(bs, &f))
+		assert.Equal(t, -3.0, f)
+	})
+
+	t.Run("Should reject invalid input", func(t *testing.T) {
+		var u8 uint8
+		bs, _ := MarshalMsgPack(256)
+		assert.NotNil(t, UnmarshalMsgPack(bs, &u8))
+		bs, _ = MarshalMsgPack(-1)
+		assert.NotNil(t, UnmarshalMsgPack(bs, &u8))
+		var s string
+		assert.NotNil(t, UnmarshalMsgPack(bs, &s))
+		assert.NotNil(t, UnmarshalMsgPack([]byte{0xdd, 0xff, 0xff, 0xff, 0xff}, &s))
+		var arr []interface{}
+		assert.NotNil(t, UnmarshalMsgPack([]byte{0xdd, 0xff, 0xff, 0xff, 0xff}, &arr))
+		assert.NotNil(t, UnmarshalMsgPack([]byte{0xc1}, &arr))
+		assert.NotNil(t, UnmarshalMsgPack(bs, s))
+		_, err := MarshalMsgPack(make(chan int))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Should limit nesting depth", func(t *testing.T) {
+		// 约 1M 层嵌套的 fixarray，不限制深度时会耗尽 goroutine 栈
+		deep := bytes.Repeat([]byte{0x91}, 1<<20)
+		deep = append(deep, _mpNil)
+		var v interface{}
+		assert.Equal(t, errMsgPackDepth, UnmarshalMsgPack(deep, &v))
+		var arr []interface{}
+		assert.Equal(t, errMsgPackDepth, UnmarshalMsgPack(deep, &arr))
+		deepMap := bytes.Repeat([]byte{0x81, 0xa1, 'k'}, 1<<18)
+		deepMap = append(deepMap, _mpNil)
+		var m map[string]interface{}
+		assert.Equal(t, errMsgPackDepth, UnmarshalMsgPack(deepMap, &m))
+
+		ok := append(bytes.Repeat([]byte{0x91}, _maxMsgPackDepth), _mpNil)
+		assert.Nil(t, UnmarshalMsgPack(ok, &v))
+	})
+
+	t.Run("Should encode timestamps by range", func(t *testing.T) {
+		bs, err := MarshalMsgPack(time.Unix(1<<32-1, 0))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte{_mpFixExt4, _mpExtTimestamp, 0xff, 0xff, 0xff, 0xff}, bs)
+		bs, err = MarshalMsgPack(time.Unix(1<<33, 0))
+		assert.Nil(t, err)
+		assert.Equal(t, byte(_mpFixExt8), bs[0])
+		var tm time.Time
+		assert.Nil(t, UnmarshalMsgPack(bs, &tm))
+		assert.Equal(t, int64(1<<33), tm.Unix())
+	})
+
+	t.Run("Should skip unknown ext types", func(t *testing.T) {
+		bs, err := MarshalMsgPack(map[string]interface{}{
+			"id":    int64(1),
+			"extra": MsgPackExt{Type: 1, Data: []byte{1, 2, 3, 4, 5}},
+		})
+		assert.Nil(t, err)
+		var inner mpInner
+		assert.Nil(t, UnmarshalMsgPack(bs, &inner))
+		assert.Equal(t, mpInner{ID: 1}, inner)
+		var tm time.Time
+		bs, _ = MarshalMsgPack(MsgPackExt{Type: 1, Data: []byte{1, 2, 3, 4}})
+		assert.NotNil(t, UnmarshalMsgPack(bs, &tm))
+	})
+
+	t.Run("Should reject unhashable map keys", func(t *testing.T) {
+		// {[1]: 1}，key 为数组
+		bs := []byte{0x81, 0x91, 0x01, 0x01}
+		var m map[interface{}]interface{}
+		assert.NotNil(t, UnmarshalMsgPack(bs, &m))
+		var am map[[1]interface{}]int
+		assert.NotNil(t, UnmarshalMsgPack([]byte{0x81, 0x91, 0x90, 0x01}, &am))
+		assert.Nil(t, UnmarshalMsgPack(bs, &am))
+	})
+
+	t.Run("Should follow json marshalers", func(t *testing.T) {
+		type payload struct {
+			Raw    json.RawMessage `msgpack:"raw"`
+			Number json.Number     `msgpack:"number"`
+		}
+		in := payload{Raw: json.RawMessage(`{"a":[1,"b",true]}`), Number: "12.5"}
+		bs, err := MarshalMsgPack(in)
+		assert.Nil(t, err)
+		var any map[string]interface{}
+		assert.Nil(t, UnmarshalMsgPack(bs, &any))
+		assert.Equal(t, map[string]interface{}{
+			"raw":    map[string]interface{}{"a": []interface{}{int64(1), "b", true}},
+			"number": 12.5,
+		}, any)
+		var out payload
+		assert.Nil(t, UnmarshalMsgPack(bs, &out))
+		assert.JSONEq(t, string(in.Raw), string(out.Raw))
+		assert.Equal(t, in.Number, out.Number)
+	})
+
+	t.Run("Should reject cyclic values", func(t *testing.T) {
+		type node struct {
+			Next *node
+		}
+		n := &node{}
+		n.Next = n
+		_, err := MarshalMsgPack(n)
+		assert.Equal(t, errMsgPackDepth, err)
+		arr := []interface{}{nil}
+		arr[0] = arr
+		_, err = MarshalMsgPack(arr)
+		assert.Equal(t, errMsgPackDepth, err)
+		m := map[string]interface{}{}
+		m["self"] = m
+		_, err = MarshalMsgPack(m)
+		assert.Equal(t, errMsgPackDepth, err)
+	})
+}
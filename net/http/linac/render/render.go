@@ -10,8 +10,20 @@ type IRender interface {
 	ContentType() string
 }
 
+// IStreamRender 流式 render
+// 直接将内容写入 io，不再在内存中缓存整个响应体
+type IStreamRender interface {
+	IRender
+	io.WriterTo
+}
+
 // Write 将 render 渲染到 io 中
+// 如果 render 实现了 IStreamRender，优先流式写入
 func Write(render IRender, w io.Writer) error {
+	if sr, ok := render.(IStreamRender); ok {
+		_, err := sr.WriteTo(w)
+		return err
+	}
 	bs, err := render.Render()
 	if err != nil {
 		return err
@@ -22,3 +34,15 @@ func Write(render IRender, w io.Writer) error {
 	}
 	return nil
 }
+
+// countWriter 记录写入字节数
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (n int, err error) {
+	n, err = cw.w.Write(p)
+	cw.n += int64(n)
+	return
+}
@@ -0,0 +1,211 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// onlyRender 只实现 IRender，用于对比整体缓存的写入方式
+type onlyRender struct {
+	IRender
+}
+
+type payloadRow struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+func bigPayload(n int) JSON {
+	rows := make([]payloadRow, 0, n)
+	for i := 0; i < n; i++ {
+		rows = append(rows, payloadRow{ID: i, Name: strings.Repeat("n", 64), Email: "user@example.com"})
+	}
+	return JSON{Code: 0, Data: rows}
+}
+
+func TestWrite(t *testing.T) {
+	t.Run("Should stream the same content as Render", func(t *testing.T) {
+		r := JSON{Code: 1, Err: "err", Data: map[string]int{"a": 1}}
+		bs, err := r.Render()
+		assert.Nil(t, err)
+		var buf bytes.Buffer
+		assert.Nil(t, Write(r, &buf))
+		assert.Equal(t, string(bs), buf.String())
+	})
+
+	t.Run("Should stream slices with the same output as Render", func(t *testing.T) {
+		renders := []IRender{
+			JSON{},
+			JSON{Data: []int(nil)},
+			JSON{Data: []int{}},
+			JSON{Data: [2]string{"<a>", "&"}},
+			JSON{Data: []interface{}{1, nil, []int{2, 3}, map[string]int{"b": 2, "a": 1}}},
+			JSON{Data: []byte("bytes")},
+			JSON{Data: []time.Time{time.Unix(0, 0).UTC()}},
+			JSON{Data: []upperText{"a", "b"}},
+			JSON{Data: []pointerJSON{{1}, {2}}},
+			JSON{Data: [][]pointerJSON{{{1}}, {}}},
+			JSONMap(nil),
+			JSONMap{},
+			JSONMap{"list": []string{"x", "y"}, "a": 1, "<": ">"},
+		}
+		for _, r := range renders {
+			bs, err := r.Render()
+			assert.Nil(t, err)
+			var buf bytes.Buffer
+			n, err := r.(IStreamRender).WriteTo(&buf)
+			assert.Nil(t, err)
+			assert.Equal(t, string(bs), buf.String())
+			assert.Equal(t, int64(len(bs)), n)
+		}
+	})
+
+	t.Run("Should write slice elements separately", func(t *testing.T) {
+		w := &countingWriter{}
+		assert.Nil(t, Write(bigPayload(1000), w))
+		assert.True(t, w.writes > 1000)
+		assert.True(t, w.max < 256)
+	})
+
+	t.Run("Should return encode error", func(t *testing.T) {
+		_, err := JSON{Data: []interface{}{1, func() {}}}.WriteTo(ioutil.Discard)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Should report written bytes", func(t *testing.T) {
+		var buf bytes.Buffer
+		n, err := String{Content: "hello"}.WriteTo(&buf)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(5), n)
+		n, err = JSONMap{"a": 1}.WriteTo(&buf)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(buf.Len()-5), n)
+	})
+
+	t.Run("Should fall back to Render", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.Nil(t, Write(onlyRender{String{Content: "hello"}}, &buf))
+		assert.Equal(t, "hello", buf.String())
+	})
+}
+
+// upperText 实现 encoding.TextMarshaler
+type upperText string
+
+func (u upperText) MarshalText() ([]byte, error) {
+	return []byte(strings.ToUpper(string(u))), nil
+}
+
+// pointerJSON 以指针接收者实现 json.Marshaler
+type pointerJSON struct {
+	v int
+}
+
+func (p *pointerJSON) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`{"v":%d}`, p.v)), nil
+}
+
+// countingWriter 记录写入次数与单次写入的最大字节数
+type countingWriter struct {
+	writes, max int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if len(p) > w.max {
+		w.max = len(p)
+	}
+	return len(p), nil
+}
+
+// BenchmarkWrite 对比不同大小的响应整体缓存与流式写入的内存占用
+// 整体缓存的 B/op 随响应大小线性增长，流式写入只与单个元素的大小有关
+func BenchmarkWrite(b *testing.B) {
+	for _, n := range []int{1000, 10000, 100000} {
+		r := bigPayload(n)
+		bs, _ := r.Render()
+		renders := []struct {
+			name string
+			r    IRender
+		}{
+			{"buffered", onlyRender{r}},
+			{"stream", r},
+		}
+		for _, c := range renders {
+			b.Run(fmt.Sprintf("%s/rows=%d", c.name, n), func(b *testing.B) {
+				b.SetBytes(int64(len(bs)))
+				b.ReportAllocs()
+				var before, after runtime.MemStats
+				runtime.ReadMemStats(&before)
+				for i := 0; i < b.N; i++ {
+					if err := Write(c.r, ioutil.Discard); err != nil {
+						b.Fatal(err)
+					}
+				}
+				runtime.ReadMemStats(&after)
+				// 每次写入分配的字节数与响应大小的比值
+				b.ReportMetric(float64(after.TotalAlloc-before.TotalAlloc)/float64(b.N)/float64(len(bs)), "alloc/payload")
+			})
+		}
+	}
+}
+
+type flushBuffer struct {
+	bytes.Buffer
+	flushed []int
+}
+
+func (fb *flushBuffer) Flush() {
+	fb.flushed = append(fb.flushed, fb.Len())
+}
+
+func TestStream(t *testing.T) {
+	t.Run("Should write ndjson from channel", func(t *testing.T) {
+		ch := make(chan interface{}, 3)
+		ch <- map[string]int{"id": 1}
+		ch <- map[string]int{"id": 2}
+		ch <- "three"
+		close(ch)
+		var buf flushBuffer
+		err := Write(NDJSON{Rows: ChanRows(ch), FlushEvery: 2}, &buf)
+		assert.Nil(t, err)
+		assert.Equal(t, "{\"id\":1}\n{\"id\":2}\n\"three\"\n", buf.String())
+		assert.Equal(t, []int{18, 26}, buf.flushed)
+	})
+
+	t.Run("Should write csv with header and delimiter", func(t *testing.T) {
+		var buf flushBuffer
+		err := Write(CSV{
+			Header: []string{"id", "name"},
+			Comma:  ';',
+			Rows: func(yield func([]string) error) error {
+				for _, r := range [][]string{{"1", "a;b"}, {"2", "say \"hi\""}} {
+					if err := yield(r); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			FlushEvery: 1,
+		}, &buf)
+		assert.Nil(t, err)
+		assert.Equal(t, "id;name\r\n1;\"a;b\"\r\n2;\"say \"\"hi\"\"\"\r\n", buf.String())
+		assert.Len(t, buf.flushed, 3)
+	})
+
+	t.Run("Should stop on iterator error", func(t *testing.T) {
+		var buf bytes.Buffer
+		_, err := NDJSON{Rows: func(yield func(interface{}) error) error {
+			return yield(func() {})
+		}}.WriteTo(&buf)
+		assert.NotNil(t, err)
+	})
+}
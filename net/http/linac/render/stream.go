@@ -0,0 +1,161 @@
+package render
+
+import (
+	"bytes"
+	"encoding/csv"
+	xjson "encoding/json"
+	"io"
+)
+
+const (
+	// ContentNDJSON content-type
+	_contentNDJSON = "application/x-ndjson; charset=utf-8"
+	// ContentCSV content-type
+	_contentCSV = "text/csv; charset=utf-8"
+)
+
+// flusher 可以将缓冲数据立即发送给客户端的 writer，如 http.ResponseWriter
+type flusher interface {
+	Flush()
+}
+
+// RowFunc 行迭代器
+// 每调用一次 yield 输出一行数据，yield 返回错误时应立即停止迭代并返回该错误
+type RowFunc func(yield func(row interface{}) error) error
+
+// RecordFunc csv 记录迭代器，用法同 RowFunc
+type RecordFunc func(yield func(record []string) error) error
+
+// ChanRows 将 channel 转化为 RowFunc，channel 关闭时迭代结束
+// NOTE: 写入出错时会停止读取 channel，生产者应同时监听请求的 ctx.Done()，避免阻塞
+func ChanRows(ch <-chan interface{}) RowFunc {
+	return func(yield func(interface{}) error) error {
+		for row := range ch {
+			if err := yield(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// ChanRecords 将 channel 转化为 RecordFunc，channel 关闭时迭代结束
+func ChanRecords(ch <-chan []string) RecordFunc {
+	return func(yield func([]string) error) error {
+		for record := range ch {
+			if err := yield(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// NDJSON 以换行分隔的 json 流式输出
+type NDJSON struct {
+	Rows RowFunc
+	// FlushEvery 每写入 N 行 flush 一次，<= 0 时只在结束时 flush
+	FlushEvery int
+}
+
+// Render Render
+func (nd NDJSON) Render() ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := nd.WriteTo(&buf)
+	return buf.Bytes(), err
+}
+
+// WriteTo 逐行编码并写入 w 中
+func (nd NDJSON) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	if nd.Rows == nil {
+		return 0, nil
+	}
+	enc := xjson.NewEncoder(cw)
+	rows := 0
+	err := nd.Rows(func(row interface{}) error {
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+		rows++
+		if nd.FlushEvery > 0 && rows%nd.FlushEvery == 0 {
+			flush(w)
+		}
+		return nil
+	})
+	flush(w)
+	return cw.n, err
+}
+
+// ContentType 返回 content type
+func (nd NDJSON) ContentType() string {
+	return _contentNDJSON
+}
+
+// CSV 符合 RFC 4180 的 csv 流式输出
+type CSV struct {
+	// Header 表头，为空时不输出
+	Header []string
+	Rows   RecordFunc
+	// Comma 字段分隔符，默认为 ','
+	Comma rune
+	// FlushEvery 每写入 N 行 flush 一次，<= 0 时只在结束时 flush
+	FlushEvery int
+}
+
+// Render Render
+func (c CSV) Render() ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := c.WriteTo(&buf)
+	return buf.Bytes(), err
+}
+
+// WriteTo 逐行编码并写入 w 中
+func (c CSV) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	writer := csv.NewWriter(cw)
+	writer.UseCRLF = true
+	if c.Comma != 0 {
+		writer.Comma = c.Comma
+	}
+	if len(c.Header) > 0 {
+		if err := writer.Write(c.Header); err != nil {
+			return cw.n, err
+		}
+	}
+	var err error
+	if c.Rows != nil {
+		rows := 0
+		err = c.Rows(func(record []string) error {
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+			rows++
+			if c.FlushEvery > 0 && rows%c.FlushEvery == 0 {
+				writer.Flush()
+				if err := writer.Error(); err != nil {
+					return err
+				}
+				flush(w)
+			}
+			return nil
+		})
+	}
+	writer.Flush()
+	if err == nil {
+		err = writer.Error()
+	}
+	flush(w)
+	return cw.n, err
+}
+
+// ContentType 返回 content type
+func (c CSV) ContentType() string {
+	return _contentCSV
+}
+
+func flush(w io.Writer) {
+	if f, ok := w.(flusher); ok {
+		f.Flush()
+	}
+}
@@ -1,6 +1,9 @@
 package render
 
-import "linac"
+import (
+	"io"
+	"linac"
+)
 
 const (
 	// ContentString content-type
@@ -17,6 +20,12 @@ func (str String) Render() (res []byte, err error) {
 	return linac.StringToBytes(str.Content), nil
 }
 
+// WriteTo 将字符串直接写入 w 中
+func (str String) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, str.Content)
+	return int64(n), err
+}
+
 // ContentType 返回 content type
 func (str String) ContentType() string {
 	return _contentString
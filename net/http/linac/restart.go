@@ -0,0 +1,287 @@
+package linac
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// _envListenFDs 重启时传递给子进程的 listener 数量，文件描述符从 3 开始
+	_envListenFDs = "LINAC_LISTEN_FDS"
+	// _envReadyFD 子进程通知父进程就绪的管道的文件描述符，位于 listener 之后
+	_envReadyFD = "LINAC_READY_FD"
+	// systemd socket activation 的环境变量，文件描述符同样从 3 开始
+	_envSystemdFDs     = "LISTEN_FDS"
+	_envSystemdPID     = "LISTEN_PID"
+	_envSystemdFDNames = "LISTEN_FDNAMES"
+
+	_listenFDsStart = 3
+	// _unixPrefix 以该前缀开始的地址监听 Unix domain socket，如 unix:/run/app.sock
+	_unixPrefix = "unix:"
+
+	// _defaultRestartTimeout 未配置 RestartTimeout 时等待新进程就绪的最长时间
+	_defaultRestartTimeout = 30 * time.Second
+)
+
+var errRestartTimeout = errors.New("restart: timeout waiting for new process to be ready")
+
+var (
+	_inheritOnce sync.Once
+	_inheritMu   sync.Mutex
+	// _inherited 从父进程或 systemd 继承，尚未被使用的 listener
+	_inherited []net.Listener
+	// _pending 继承的 listener 中尚未开始服务的 listener
+	_pending map[net.Listener]struct{}
+	// _ready 通知父进程就绪的管道，通知后为 nil
+	_ready *os.File
+)
+
+// RunListener 在 l 上运行 http server engine
+// l 为 *net.TCPListener 或 *net.UnixListener 时，Restart 会将其传递给新的进程
+func (engine *Engine) RunListener(l net.Listener) error {
+	engine.addListener(l)
+	return engine.serve(l)
+}
+
+// Restart 启动新的进程并传递当前监听的 socket，用于不中断服务的升级
+// 新进程以相同的参数运行当前的可执行文件，通过 LINAC_LISTEN_FDS 继承 listener，
+// 在所有继承的 listener 上开始服务后通过 LINAC_READY_FD 指定的管道通知就绪。
+// Restart 等待新进程就绪，最多等待 ServerConfig.RestartTimeout；新进程退出或超时时结束新进程并返回错误，
+// 当前进程继续服务。调用方应在 Restart 成功后调用 Shutdown 等待处理中的请求结束后退出
+func (engine *Engine) Restart() (pid int, err error) {
+	engine.mu.Lock()
+	listeners := append([]net.Listener(nil), engine.listeners...)
+	engine.mu.Unlock()
+	files := []*os.File{os.Stdin, os.Stdout, os.Stderr}
+	for _, l := range listeners {
+		f, err := listenerFile(l)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		files = append(files, f)
+	}
+	path, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	p, err := os.StartProcess(path, os.Args, &os.ProcAttr{
+		Env:   restartEnv(os.Environ(), len(listeners)),
+		Files: append(files, w),
+	})
+	// NOTE: 关闭父进程的写端，新进程退出时读端返回 EOF
+	w.Close()
+	if err != nil {
+		return 0, err
+	}
+	timeout := engine.GetConfig().RestartTimeout
+	if timeout <= 0 {
+		timeout = _defaultRestartTimeout
+	}
+	if err = waitReady(r, timeout); err != nil {
+		p.Kill()
+		go p.Wait()
+		return 0, err
+	}
+	for _, l := range listeners {
+		// 新进程仍在使用 socket 文件，关闭时不删除
+		keepSocketFile(l)
+	}
+	return p.Pid, nil
+}
+
+// waitReady 等待新进程通过管道 r 通知就绪
+func waitReady(r *os.File, timeout time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		var b [1]byte
+		_, err := r.Read(b[:])
+		done <- err
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("restart: new process exited before ready: %v", err)
+		}
+		return nil
+	case <-timer.C:
+		return errRestartTimeout
+	}
+}
+
+// notifyReady 在 l 上开始服务时调用，所有继承的 listener 都开始服务后通知父进程就绪
+func notifyReady(l net.Listener) {
+	loadInherited()
+	_inheritMu.Lock()
+	defer _inheritMu.Unlock()
+	delete(_pending, l)
+	if _ready == nil || len(_pending) > 0 {
+		return
+	}
+	if _, err := _ready.Write([]byte{1}); err != nil {
+		log.Printf("http server notify ready error: %v", err)
+	}
+	_ready.Close()
+	_ready = nil
+}
+
+// listen 监听 address，优先使用从父进程或 systemd 继承的 listener
+// address 以 unix: 开头时监听 Unix domain socket，并删除残留的 socket 文件
+func (engine *Engine) listen(address string) (l net.Listener, err error) {
+	network := "tcp"
+	if strings.HasPrefix(address, _unixPrefix) {
+		network, address = "unix", strings.TrimPrefix(address, _unixPrefix)
+	}
+	if l = inheritListener(network, address); l == nil {
+		if network == "unix" {
+			if info, err := os.Stat(address); err == nil && info.Mode()&os.ModeSocket != 0 {
+				os.Remove(address)
+			}
+		}
+		if l, err = net.Listen(network, address); err != nil {
+			return nil, err
+		}
+	}
+	engine.addListener(l)
+	return l, nil
+}
+
+// addListener 记录可以传递给新进程的 listener
+func (engine *Engine) addListener(l net.Listener) {
+	switch l.(type) {
+	case *net.TCPListener, *net.UnixListener:
+		engine.mu.Lock()
+		engine.listeners = append(engine.listeners, l)
+		engine.mu.Unlock()
+	}
+}
+
+// removeListener 删除已经停止服务的 listener
+func (engine *Engine) removeListener(l net.Listener) {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	for i, v := range engine.listeners {
+		if v == l {
+			engine.listeners = append(engine.listeners[:i], engine.listeners[i+1:]...)
+			return
+		}
+	}
+}
+
+// loadInherited 读取继承的 listener 与就绪通知的管道，只执行一次
+func loadInherited() {
+	_inheritOnce.Do(func() {
+		_inherited, _ready = inheritedListeners()
+		_pending = make(map[net.Listener]struct{}, len(_inherited))
+		for _, l := range _inherited {
+			_pending[l] = struct{}{}
+		}
+	})
+}
+
+// inheritListener 返回地址相同的继承的 listener，没有时返回 nil
+func inheritListener(network, address string) net.Listener {
+	loadInherited()
+	_inheritMu.Lock()
+	defer _inheritMu.Unlock()
+	for i, l := range _inherited {
+		if sameAddr(l.Addr(), network, address) {
+			_inherited = append(_inherited[:i], _inherited[i+1:]...)
+			return l
+		}
+	}
+	return nil
+}
+
+// inheritedListeners 读取 LINAC_LISTEN_FDS 或 systemd 的 LISTEN_FDS，以及 LINAC_READY_FD 指定的管道，
+// 并删除这些环境变量，避免再传递给子进程
+func inheritedListeners() (listeners []net.Listener, ready *os.File) {
+	n, _ := strconv.Atoi(os.Getenv(_envListenFDs))
+	if n <= 0 && os.Getenv(_envSystemdPID) == strconv.Itoa(os.Getpid()) {
+		n, _ = strconv.Atoi(os.Getenv(_envSystemdFDs))
+	}
+	if fd, err := strconv.Atoi(os.Getenv(_envReadyFD)); err == nil && fd >= _listenFDsStart {
+		ready = os.NewFile(uintptr(fd), "ready")
+	}
+	for _, env := range []string{_envListenFDs, _envReadyFD, _envSystemdFDs, _envSystemdPID, _envSystemdFDNames} {
+		os.Unsetenv(env)
+	}
+	return listenersFromFDs(_listenFDsStart, n), ready
+}
+
+// listenersFromFDs 将从 start 开始的 n 个文件描述符转换为 listener
+func listenersFromFDs(start uintptr, n int) []net.Listener {
+	listeners := make([]net.Listener, 0, n)
+	for fd := start; fd < start+uintptr(n); fd++ {
+		f := os.NewFile(fd, "listener-"+strconv.Itoa(int(fd)))
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			log.Printf("http server inherit listener fd=%d error: %v", fd, err)
+			continue
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners
+}
+
+// sameAddr 判断 addr 是否为 address 的监听地址，未指定 IP 的地址与任意的未指定 IP 相同
+func sameAddr(addr net.Addr, network, address string) bool {
+	if network == "unix" {
+		return addr.Network() == "unix" && addr.String() == address
+	}
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	want, err := net.ResolveTCPAddr(network, address)
+	if err != nil || want.Port != tcp.Port {
+		return false
+	}
+	if len(want.IP) == 0 || want.IP.IsUnspecified() {
+		return tcp.IP.IsUnspecified()
+	}
+	return want.IP.Equal(tcp.IP)
+}
+
+// listenerFile 返回 listener 的文件描述符的副本
+func listenerFile(l net.Listener) (*os.File, error) {
+	switch l := l.(type) {
+	case *net.TCPListener:
+		return l.File()
+	case *net.UnixListener:
+		return l.File()
+	}
+	return nil, &net.OpError{Op: "file", Net: l.Addr().Network(), Addr: l.Addr(), Err: os.ErrInvalid}
+}
+
+// restartEnv 返回子进程的环境变量，替换继承 listener 相关的变量
+// n 个 listener 的文件描述符从 3 开始，就绪通知的管道紧随其后
+func restartEnv(environ []string, n int) []string {
+	env := make([]string, 0, len(environ)+2)
+	for _, kv := range environ {
+		switch strings.SplitN(kv, "=", 2)[0] {
+		case _envListenFDs, _envReadyFD, _envSystemdFDs, _envSystemdPID, _envSystemdFDNames:
+			continue
+		}
+		env = append(env, kv)
+	}
+	return append(env,
+		_envListenFDs+"="+strconv.Itoa(n),
+		_envReadyFD+"="+strconv.Itoa(_listenFDsStart+n),
+	)
+}
@@ -0,0 +1,14 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package linac
+
+import (
+	"net"
+	"os"
+)
+
+// _restartSignal windows、plan9 等平台不支持传递 listener，不处理重启信号
+var _restartSignal os.Signal
+
+func keepSocketFile(l net.Listener) {}
@@ -0,0 +1,244 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package linac
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "linac-unix")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "app.sock")
+	// 残留的 socket 文件会被删除
+	stale, err := net.Listen("unix", sock)
+	assert.Nil(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	engine := NewEngine()
+	engine.GET("/unix", "unix", func(ctx *Context) {
+		ctx.String(200, "unix")
+	})
+	errc := make(chan error, 1)
+	go func() {
+		errc <- engine.Run(_unixPrefix + sock)
+	}()
+	for engine.Server() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		},
+	}}
+	resp, err := client.Get("http://unix/unix")
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "unix", string(body))
+	assert.Len(t, engine.listeners, 1)
+
+	assert.Nil(t, engine.Shutdown(context.Background()))
+	assert.Nil(t, <-errc)
+	_, err = os.Stat(sock)
+	assert.True(t, os.IsNotExist(err))
+}
+
+// _envTestRestart 设置时 TestRestart 作为 Restart 启动的新进程运行，值为监听的地址
+const _envTestRestart = "LINAC_TEST_RESTART_ADDR"
+
+func TestRestart(t *testing.T) {
+	if addr := os.Getenv(_envTestRestart); addr != "" {
+		// 新进程：在继承的 listener 上服务，直到被父进程结束
+		engine := NewEngine()
+		engine.GET("/who", "restart.who", func(ctx *Context) {
+			ctx.String(200, "child %d", os.Getpid())
+		})
+		engine.Run(addr)
+		return
+	}
+
+	stopped, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	engine := NewEngine()
+	engine.GET("/who", "restart.who", func(ctx *Context) {
+		ctx.String(200, "parent")
+	})
+	stoppedc := make(chan error, 1)
+	go func() {
+		stoppedc <- engine.RunListener(stopped)
+	}()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- engine.RunListener(l)
+	}()
+	for len(engine.runningServers()) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	// 停止服务的 listener 不影响之后的重启
+	stopped.Close()
+	assert.NotNil(t, <-stoppedc)
+	engine.mu.Lock()
+	assert.Equal(t, []net.Listener{l}, engine.listeners)
+	engine.mu.Unlock()
+
+	// 新进程只运行 TestRestart
+	args := os.Args
+	os.Args = []string{args[0], "-test.run=^TestRestart$"}
+	os.Setenv(_envTestRestart, l.Addr().String())
+	pid, err := engine.Restart()
+	os.Args = args
+	os.Unsetenv(_envTestRestart)
+	assert.Nil(t, err)
+	p, err := os.FindProcess(pid)
+	assert.Nil(t, err)
+	defer p.Wait()
+	defer p.Kill()
+
+	// 新进程就绪后父进程结束服务，新的请求由新进程处理
+	assert.Nil(t, engine.Shutdown(context.Background()))
+	assert.Nil(t, <-errc)
+	resp, err := http.Get(uri(l.Addr().String(), "/who"))
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "child "+strconv.Itoa(pid), string(body))
+}
+
+func TestInheritListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+	f, err := listenerFile(l)
+	assert.Nil(t, err)
+	// listenersFromFDs 会关闭文件描述符
+	fd, err := syscall.Dup(int(f.Fd()))
+	assert.Nil(t, err)
+	f.Close()
+	listeners := listenersFromFDs(uintptr(fd), 1)
+	assert.Len(t, listeners, 1)
+
+	_inheritOnce.Do(func() {})
+	_inheritMu.Lock()
+	_inherited = append(_inherited, listeners...)
+	_inheritMu.Unlock()
+	assert.Nil(t, inheritListener("tcp", "127.0.0.1:1"))
+
+	engine := NewEngine()
+	engine.GET("/inherit", "inherit", func(ctx *Context) {
+		ctx.String(200, "inherit")
+	})
+	errc := make(chan error, 1)
+	go func() {
+		errc <- engine.Run(l.Addr().String())
+	}()
+	for engine.Server() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	// 原 listener 关闭后继承的副本仍然可以接受连接
+	l.Close()
+	resp, err := http.Get(uri(l.Addr().String(), "/inherit"))
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "inherit", string(body))
+	assert.Nil(t, engine.Shutdown(context.Background()))
+	assert.Nil(t, <-errc)
+}
+
+func TestSameAddr(t *testing.T) {
+	cases := []struct {
+		addr    net.Addr
+		network string
+		address string
+		same    bool
+	}{
+		{&net.TCPAddr{IP: net.IPv6unspecified, Port: 8089}, "tcp", ":8089", true},
+		{&net.TCPAddr{IP: net.IPv4zero, Port: 8089}, "tcp", "0.0.0.0:8089", true},
+		{&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8089}, "tcp", "127.0.0.1:8089", true},
+		{&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8089}, "tcp", ":8089", false},
+		{&net.TCPAddr{IP: net.IPv6unspecified, Port: 8089}, "tcp", ":8090", false},
+		{&net.UnixAddr{Name: "/run/app.sock", Net: "unix"}, "unix", "/run/app.sock", true},
+		{&net.UnixAddr{Name: "/run/app.sock", Net: "unix"}, "tcp", ":8089", false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.same, sameAddr(c.addr, c.network, c.address), c.address)
+	}
+}
+
+func TestRestartEnv(t *testing.T) {
+	env := restartEnv([]string{"PATH=/bin", "LISTEN_FDS=2", "LISTEN_PID=1", "LINAC_LISTEN_FDS=1"}, 3)
+	assert.Equal(t, []string{"PATH=/bin", "LINAC_LISTEN_FDS=3", "LINAC_READY_FD=6"}, env)
+}
+
+func TestWaitReady(t *testing.T) {
+	t.Run("ready", func(t *testing.T) {
+		r, w, err := os.Pipe()
+		assert.Nil(t, err)
+		defer r.Close()
+		w.Write([]byte{1})
+		w.Close()
+		assert.Nil(t, waitReady(r, time.Second))
+	})
+
+	t.Run("exited", func(t *testing.T) {
+		r, w, err := os.Pipe()
+		assert.Nil(t, err)
+		defer r.Close()
+		w.Close()
+		assert.NotNil(t, waitReady(r, time.Second))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		r, w, err := os.Pipe()
+		assert.Nil(t, err)
+		defer r.Close()
+		defer w.Close()
+		assert.Equal(t, errRestartTimeout, waitReady(r, time.Millisecond*50))
+	})
+}
+
+func TestNotifyReady(t *testing.T) {
+	l1, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l1.Close()
+	l2, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l2.Close()
+	r, w, err := os.Pipe()
+	assert.Nil(t, err)
+	defer r.Close()
+
+	loadInherited()
+	_inheritMu.Lock()
+	_pending = map[net.Listener]struct{}{l1: {}, l2: {}}
+	_ready = w
+	_inheritMu.Unlock()
+
+	// 所有继承的 listener 开始服务后才通知就绪
+	notifyReady(l1)
+	_inheritMu.Lock()
+	assert.NotNil(t, _ready)
+	_inheritMu.Unlock()
+	notifyReady(l2)
+	assert.Nil(t, waitReady(r, time.Second))
+	_inheritMu.Lock()
+	assert.Nil(t, _ready)
+	_inheritMu.Unlock()
+}
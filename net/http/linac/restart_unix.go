@@ -0,0 +1,20 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package linac
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// _restartSignal RunWithSignals 收到后调用 Restart 的信号
+var _restartSignal os.Signal = syscall.SIGUSR2
+
+// keepSocketFile 关闭 Unix domain socket 的 listener 时不删除 socket 文件
+func keepSocketFile(l net.Listener) {
+	if ul, ok := l.(*net.UnixListener); ok {
+		ul.SetUnlinkOnClose(false)
+	}
+}
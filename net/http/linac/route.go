@@ -20,6 +20,7 @@ func newRoute(pattern, method, name string, handler ...Handler) *Route {
 		method:   method,
 		handlers: handler,
 		config:   &atomic.Value{},
+		rate:     &atomic.Value{},
 	}
 }
 
@@ -70,6 +71,9 @@ func (group *RouteGroup) Group(path, name string, register func(*RouteGroup) *Ro
 		routes:   make(map[string]*Route),
 	}
 	newGroup = register(newGroup)
+	if group.routes == nil {
+		group.routes = make(map[string]*Route)
+	}
 	for name, route := range newGroup.routes {
 		if _, ok := group.GetRoute(name); ok {
 			panic(fmt.Errorf("add route error, name '%s' already exist", name))
@@ -129,14 +133,22 @@ func (group *RouteGroup) fullName(name string) string {
 }
 
 func (group *RouteGroup) mergeHandlers(handlers ...Handler) []Handler {
-	return append(group.handlers, handlers...)
+	merged := make([]Handler, 0, len(group.handlers)+len(handlers))
+	merged = append(merged, group.handlers...)
+	return append(merged, handlers...)
 }
 
 // RouteConfig 路由配置
 // 为路由定制配置选项
 type RouteConfig struct {
-	Timeout        time.Duration
+	// Timeout 路由的超时时间，覆盖服务器配置，为 0 时不限制
+	Timeout time.Duration
+	// MaxRequestBody 请求体的最大字节数，超过时返回 413，覆盖服务器配置，为 0 时不限制
 	MaxRequestBody int64
+	// MaxMultipartMemory 解析 multipart 表单时保存在内存中的最大字节数，超过的部分写入临时文件，为 0 时使用服务器配置
+	MaxMultipartMemory int64
+	// MaxUploadSize multipart 请求体的最大字节数，覆盖 MaxRequestBody，用于允许上传较大的文件
+	MaxUploadSize int64
 }
 
 // Route model
@@ -148,6 +160,12 @@ type Route struct {
 	handlers []Handler
 
 	config *atomic.Value
+	rate   *atomic.Value
+}
+
+// Name 返回路由名称
+func (route *Route) Name() string {
+	return route.name
 }
 
 // SetConfig 为路由添加特定的配置
@@ -161,6 +179,17 @@ func (route *Route) GetConfig() (config *RouteConfig, ok bool) {
 	return
 }
 
+// SetRateLimit 设置路由的限流规则，覆盖 RateLimitConfig 中的默认规则
+func (route *Route) SetRateLimit(rate *Rate) {
+	route.rate.Store(rate)
+}
+
+// rateLimit 返回路由的限流规则，未设置时返回 nil
+func (route *Route) rateLimit() *Rate {
+	rate, _ := route.rate.Load().(*Rate)
+	return rate
+}
+
 // handle 处理http请求
 // 1.解析路由参数
 // 2.调用 Handler 处理 context
@@ -175,6 +204,7 @@ func (route *Route) handle(ctx *Context) {
 	}
 	ctx.Params = params
 	ctx.Handlers = route.handlers
+	ctx.route = route
 	ctx.Next()
 }
 
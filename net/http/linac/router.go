@@ -38,6 +38,8 @@ func (router *Router) getNotFoundHandler() Handler {
 
 // ServeHTTP 响应http请求
 func (router *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	router.engine.active.Add(1)
+	defer router.engine.active.Done()
 	router.handleContext(&Context{
 		Writer:  w,
 		Request: r,
@@ -47,40 +49,67 @@ func (router *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 // handleContext 处理context, 添加超时
+// 未匹配到路由时同样执行全局中间件，以便 CORS 等中间件处理预检请求
 func (router *Router) handleContext(ctx *Context) {
-	if route, ok := router.metchRoute(ctx); ok {
-		var (
-			cancel         func()
-			tm             time.Duration
-			maxRequestBody int64
-		)
-		conf := router.engine.GetConfig()
-		tm = conf.Timeout
-		maxRequestBody = conf.MaxRequestBody
+	var (
+		cancel func()
+		tm     time.Duration
+	)
+	conf := router.engine.GetConfig()
+	tm = conf.Timeout
+	maxRequestBody, maxMultipartMemory, maxUploadSize := conf.MaxRequestBody, conf.MaxMultipartMemory, conf.MaxUploadSize
+	route, ok := router.metchRoute(ctx)
+	if ok {
 		if conf, ok := route.GetConfig(); ok {
 			tm = conf.Timeout
 			maxRequestBody = conf.MaxRequestBody
+			if conf.MaxMultipartMemory > 0 {
+				maxMultipartMemory = conf.MaxMultipartMemory
+			}
+			if conf.MaxUploadSize > 0 {
+				maxUploadSize = conf.MaxUploadSize
+			}
 		}
+	}
+	if maxMultipartMemory <= 0 {
+		maxMultipartMemory = _defaultMaxMultipartMemory
+	}
+	// multipart 请求使用单独的上传大小限制
+	if maxUploadSize > 0 && strings.Contains(ctx.Request.Header.Get("Content-Type"), "multipart/form-data") {
+		maxRequestBody = maxUploadSize
+	}
 
-		req := ctx.Request
-		ctype := req.Header.Get("Content-Type")
-		switch {
-		case strings.Contains(ctype, "multipart/form-data"):
-			req.ParseMultipartForm(maxRequestBody)
-		default:
-			req.ParseForm()
-		}
+	ctx.maxRequestBody = maxRequestBody
+	ctx.maxMultipartMemory = maxMultipartMemory
+	ctx.trustedProxies = router.engine.trustedProxies()
+	ctx.clientIPHeader = conf.ClientIPHeader
+	ctx.closing = router.engine.closing
+	err := ctx.limitBody()
+	if err == nil {
+		err = ctx.parseBody()
+	}
 
-		c := context.Background()
-		if tm > 0 {
-			ctx.Context, cancel = context.WithTimeout(c, tm)
-		} else {
-			ctx.Context, cancel = context.WithCancel(c)
-		}
-		defer cancel()
-		route.handle(ctx)
+	c := context.Background()
+	if tm > 0 {
+		ctx.Context, cancel = context.WithTimeout(c, tm)
 	} else {
-		router.getNotFoundHandler()(ctx)
+		ctx.Context, cancel = context.WithCancel(c)
+	}
+	defer cancel()
+	switch {
+	case isBodyTooLarge(err):
+		// 请求体超过限制时仍然执行全局中间件，以便记录日志与指标
+		ctx.route = route
+		ctx.Params = make(map[string]interface{})
+		ctx.Handlers = router.mergeHandlers(requestEntityTooLarge)
+		ctx.Next()
+	case ok:
+		route.handle(ctx)
+		observeTimeout(ctx)
+	default:
+		ctx.Params = make(map[string]interface{})
+		ctx.Handlers = router.mergeHandlers(router.getNotFoundHandler())
+		ctx.Next()
 	}
 }
 
@@ -0,0 +1,176 @@
+package linac
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// _cspNonce CSP 中 nonce 的占位符
+	_cspNonce = "{nonce}"
+)
+
+var (
+	_defaultSecureConfig = &SecureConfig{
+		HSTSMaxAge:            time.Hour * time.Duration(24*365),
+		HSTSIncludeSubdomains: true,
+		ContentTypeNosniff:    true,
+		FrameOptions:          "SAMEORIGIN",
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+	}
+)
+
+type cspNonceKey struct{}
+
+// SecureConfig 安全响应头配置
+// 为空的选项不设置对应的响应头
+type SecureConfig struct {
+	// HSTSMaxAge Strict-Transport-Security 的 max-age，只对 https 请求生效，0 表示不设置
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	// ContentTypeNosniff 设置 X-Content-Type-Options: nosniff
+	ContentTypeNosniff bool
+	// FrameOptions X-Frame-Options，如 DENY、SAMEORIGIN
+	FrameOptions string
+	// ReferrerPolicy Referrer-Policy，如 strict-origin-when-cross-origin
+	ReferrerPolicy string
+	// PermissionsPolicy Permissions-Policy，如 "camera=(), microphone=()"
+	PermissionsPolicy string
+	// ContentSecurityPolicy Content-Security-Policy，
+	// 其中的 {nonce} 会替换为每个请求随机生成的 nonce，如 "script-src 'self' 'nonce-{nonce}'"
+	ContentSecurityPolicy string
+	// CSPReportOnly 使用 Content-Security-Policy-Report-Only
+	CSPReportOnly bool
+
+	// SSLRedirect 将 http 请求重定向到 https
+	SSLRedirect bool
+	// SSLHost 重定向的域名，为空时使用请求的 Host
+	SSLHost string
+	// TrustedProxies 可信代理的 IP 或 CIDR，只有来自可信代理的请求
+	// 才会根据 X-Forwarded-Proto 或 Forwarded 判断是否为 https 请求，为空时使用 ServerConfig.TrustedProxies
+	TrustedProxies []string
+}
+
+// Secure 使用默认配置的安全响应头中间件
+// 默认设置一年的 HSTS、X-Content-Type-Options: nosniff、X-Frame-Options: SAMEORIGIN
+// 与 Referrer-Policy: strict-origin-when-cross-origin
+func Secure() Handler {
+	return SecureWithConfig(nil)
+}
+
+// SecureWithConfig 安全响应头中间件
+// 在 RouteGroup 中再次使用该中间件会完全覆盖 engine 上的设置，配置中为空的响应头会被删除
+func SecureWithConfig(conf *SecureConfig) Handler {
+	if conf == nil {
+		conf = _defaultSecureConfig
+	}
+	trusted, err := parseCIDRs(conf.TrustedProxies)
+	if err != nil {
+		panic(err)
+	}
+	hsts := ""
+	if conf.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.FormatInt(int64(conf.HSTSMaxAge/time.Second), 10)
+		if conf.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if conf.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	cspHeader := "Content-Security-Policy"
+	if conf.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+	return func(ctx *Context) {
+		req := ctx.Request
+		proxies := trusted
+		if len(proxies) == 0 {
+			proxies = ctx.trustedProxies
+		}
+		https := isHTTPS(req, proxies)
+		if conf.SSLRedirect && !https {
+			host := conf.SSLHost
+			if host == "" {
+				host = req.Host
+			}
+			code := http.StatusMovedPermanently
+			if req.Method != http.MethodGet && req.Method != http.MethodHead {
+				code = http.StatusPermanentRedirect
+			}
+			ctx.Writer.Header().Set("Location", "https://"+host+req.URL.RequestURI())
+			ctx.Abort(code)
+			return
+		}
+
+		header := ctx.Writer.Header()
+		setHeader(header, "Strict-Transport-Security", hsts, https)
+		setHeader(header, "X-Content-Type-Options", "nosniff", conf.ContentTypeNosniff)
+		setHeader(header, "X-Frame-Options", conf.FrameOptions, true)
+		setHeader(header, "Referrer-Policy", conf.ReferrerPolicy, true)
+		setHeader(header, "Permissions-Policy", conf.PermissionsPolicy, true)
+		header.Del("Content-Security-Policy")
+		header.Del("Content-Security-Policy-Report-Only")
+		csp := conf.ContentSecurityPolicy
+		if strings.Contains(csp, _cspNonce) {
+			nonce := newCSPNonce()
+			ctx.Context = context.WithValue(ctx.Context, cspNonceKey{}, nonce)
+			csp = strings.Replace(csp, _cspNonce, nonce, -1)
+		}
+		setHeader(header, cspHeader, csp, true)
+	}
+}
+
+// CSPNonce 返回当前请求的 CSP nonce，用于模板中的 <script nonce="...">，
+// 未使用 Secure 中间件或 CSP 中没有 {nonce} 时返回空字符串
+func (ctx *Context) CSPNonce() string {
+	if ctx.Context == nil {
+		return ""
+	}
+	nonce, _ := ctx.Value(cspNonceKey{}).(string)
+	return nonce
+}
+
+// setHeader 设置响应头，value 为空或 ok 为 false 时删除该响应头
+func setHeader(header http.Header, key, value string, ok bool) {
+	if value == "" || !ok {
+		header.Del(key)
+		return
+	}
+	header.Set(key, value)
+}
+
+// isHTTPS 判断请求是否为 https，来自可信代理的请求根据 X-Forwarded-Proto 或 Forwarded 判断
+func isHTTPS(req *http.Request, trusted []*net.IPNet) bool {
+	if req.TLS != nil {
+		return true
+	}
+	if !containsIP(trusted, remoteIP(req)) {
+		return false
+	}
+	if proto := req.Header.Get("X-Forwarded-Proto"); proto != "" {
+		return strings.EqualFold(strings.TrimSpace(strings.Split(proto, ",")[0]), "https")
+	}
+	for _, part := range strings.Split(strings.Split(req.Header.Get("Forwarded"), ",")[0], ";") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) == 2 && strings.EqualFold(kv[0], "proto") {
+			return strings.EqualFold(strings.Trim(kv[1], `"`), "https")
+		}
+	}
+	return false
+}
+
+func newCSPNonce() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
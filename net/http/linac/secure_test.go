@@ -0,0 +1,104 @@
+package linac
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecure(t *testing.T) {
+	engine := NewEngine()
+	engine.Use(Secure())
+	engine.GET("/page", "secure.page", func(ctx *Context) {
+		ctx.String(http.StatusOK, ctx.CSPNonce())
+	})
+	engine.Group("/embed", "embed", func(group *RouteGroup) *RouteGroup {
+		group.GET("/widget", "widget", func(ctx *Context) {
+			ctx.String(http.StatusOK, ctx.CSPNonce())
+		})
+		return group
+	}, SecureWithConfig(&SecureConfig{
+		ContentTypeNosniff:    true,
+		ContentSecurityPolicy: "script-src 'self' 'nonce-{nonce}'; frame-ancestors *",
+	}))
+
+	t.Run("default", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "https://example.com/page", nil)
+		req.TLS = &tls.ConnectionState{}
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, "max-age=31536000; includeSubDomains", w.Header().Get("Strict-Transport-Security"))
+		assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+		assert.Equal(t, "SAMEORIGIN", w.Header().Get("X-Frame-Options"))
+		assert.Equal(t, "strict-origin-when-cross-origin", w.Header().Get("Referrer-Policy"))
+		assert.Equal(t, "", w.Header().Get("Content-Security-Policy"))
+		assert.Equal(t, "", w.Body.String())
+	})
+
+	t.Run("hsts only over https", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/page", nil))
+		assert.Equal(t, "", w.Header().Get("Strict-Transport-Security"))
+	})
+
+	t.Run("group override", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/embed/widget", nil))
+		nonce := w.Body.String()
+		assert.NotEmpty(t, nonce)
+		assert.Equal(t, "script-src 'self' 'nonce-"+nonce+"'; frame-ancestors *", w.Header().Get("Content-Security-Policy"))
+		assert.Equal(t, "", w.Header().Get("X-Frame-Options"))
+		assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+
+		w2 := httptest.NewRecorder()
+		engine.ServeHTTP(w2, httptest.NewRequest(http.MethodGet, "/embed/widget", nil))
+		assert.NotEqual(t, nonce, w2.Body.String())
+	})
+}
+
+func TestSecureRedirect(t *testing.T) {
+	engine := NewEngine()
+	engine.Use(SecureWithConfig(&SecureConfig{
+		SSLRedirect:    true,
+		TrustedProxies: []string{"10.0.0.0/8"},
+	}))
+	engine.GET("/page", "secure.redirect", func(ctx *Context) {
+		ctx.String(http.StatusOK, "ok")
+	})
+
+	cases := []struct {
+		name     string
+		remote   string
+		header   http.Header
+		code     int
+		location string
+	}{
+		{name: "plain http", remote: "1.2.3.4:1234", code: http.StatusMovedPermanently, location: "https://example.com/page?a=1"},
+		{name: "trusted proxy", remote: "10.0.0.1:1234", header: http.Header{"X-Forwarded-Proto": {"https"}}, code: http.StatusOK},
+		{name: "forwarded", remote: "10.0.0.1:1234", header: http.Header{"Forwarded": {"for=1.2.3.4;proto=https"}}, code: http.StatusOK},
+		{name: "untrusted proxy", remote: "1.2.3.4:1234", header: http.Header{"X-Forwarded-Proto": {"https"}}, code: http.StatusMovedPermanently},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/page?a=1", nil)
+			req.RemoteAddr = c.remote
+			for k, v := range c.header {
+				req.Header[k] = v
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			assert.Equal(t, c.code, w.Code)
+			if c.location != "" {
+				assert.Equal(t, c.location, w.Header().Get("Location"))
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "http://example.com/page", strings.NewReader("")))
+	assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+}
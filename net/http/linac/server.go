@@ -1,8 +1,12 @@
 package linac
 
 import (
+	"context"
+	"crypto/tls"
 	"log"
+	"net"
 	"net/http"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -19,19 +23,39 @@ var (
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-	Address        string
-	Timeout        time.Duration
-	ReadTimeout    time.Duration
-	WriteTimeout   time.Duration
+	Address      string
+	Timeout      time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// MaxRequestBody 请求体的最大字节数，超过时返回 413，<= 0 时不限制
 	MaxRequestBody int64
+	// MaxMultipartMemory 解析 multipart 表单时保存在内存中的最大字节数，默认 32M
+	MaxMultipartMemory int64
+	// MaxUploadSize multipart 请求体的最大字节数，为 0 时使用 MaxRequestBody
+	MaxUploadSize int64
+	// TrustedProxies 可信代理的 IP 或 CIDR，ctx.ClientIP() 只信任这些代理添加的转发请求头
+	TrustedProxies []string
+	// ClientIPHeader 可信代理设置客户端地址的请求头，默认为 X-Forwarded-For，
+	// 可以为 Forwarded 或 X-Real-IP 等，ctx.ClientIP() 只读取该请求头
+	ClientIPHeader string
+	// ProxyProtocol 不为 nil 时解析负载均衡发送的 PROXY protocol 头部
+	ProxyProtocol *ProxyProtocolConfig
+	// TLS RunTLS 使用的证书与 mTLS 配置
+	TLS *TLSConfig
+	// ShutdownTimeout RunWithSignals 等待处理中请求的最长时间，默认 30s
+	ShutdownTimeout time.Duration
+	// RestartTimeout Restart 等待新进程就绪的最长时间，默认 30s
+	RestartTimeout time.Duration
 }
 
 // NewEngine 返回一个新的 http server engine
 func NewEngine() *Engine {
 	engine := &Engine{
-		Router: NewRouter(),
-		server: &atomic.Value{},
-		config: &atomic.Value{},
+		Router:  NewRouter(),
+		server:  &atomic.Value{},
+		config:  &atomic.Value{},
+		proxies: &atomic.Value{},
+		closing: make(chan struct{}),
 	}
 	engine.Router.engine = engine
 	engine.Use(Recovery())
@@ -43,11 +67,30 @@ type Engine struct {
 	*Router
 	server *atomic.Value
 
-	config *atomic.Value
+	config  *atomic.Value
+	proxies *atomic.Value // NOTE: struct []*net.IPNet
+
+	// closing 开始关闭时关闭，通知长连接
+	closing   chan struct{}
+	closeOnce sync.Once
+	// active 处理中的请求
+	active sync.WaitGroup
+	mu     sync.Mutex
+	hooks  []func(ctx context.Context) error
+	// listeners 重启时传递给新进程的 listener
+	listeners []net.Listener
+	// servers 正在运行的 http server，Shutdown 时全部关闭
+	servers map[*http.Server]struct{}
 }
 
 // SetConfig 设置服务器配置
+// TrustedProxies 中有无法解析的地址时 panic
 func (engine *Engine) SetConfig(conf *ServerConfig) {
+	proxies, err := parseCIDRs(conf.TrustedProxies)
+	if err != nil {
+		panic(err)
+	}
+	engine.proxies.Store(proxies)
 	engine.config.Store(conf)
 }
 
@@ -59,23 +102,106 @@ func (engine *Engine) GetConfig() (conf *ServerConfig) {
 	return _defaultConfig
 }
 
+// trustedProxies 返回解析后的可信代理
+func (engine *Engine) trustedProxies() []*net.IPNet {
+	proxies, _ := engine.proxies.Load().([]*net.IPNet)
+	return proxies
+}
+
 // Run 运行 http server engine
-func (engine *Engine) Run(address string) {
+// address 以 unix: 开头时监听 Unix domain socket，如 unix:/run/app.sock；
+// 从父进程或 systemd 继承了相同地址的 listener 时直接使用。
+// 调用 Shutdown 后返回 nil，监听或服务失败时返回错误
+func (engine *Engine) Run(address string) error {
+	l, err := engine.listen(address)
+	if err != nil {
+		return err
+	}
+	return engine.serve(l)
+}
+
+// serve 在 l 上运行 http server
+func (engine *Engine) serve(l net.Listener) error {
+	return engine.serveTLS(l, nil)
+}
+
+// serveTLS 在 l 上运行 http server，tlsConf 不为 nil 时使用 HTTPS
+// PROXY protocol 头部在 TLS 握手之前，因此先包装 proxy listener
+func (engine *Engine) serveTLS(l net.Listener, tlsConf *tls.Config) (err error) {
 	conf := engine.GetConfig()
+	origin := l
+	// 停止服务的 listener 已经关闭，不再传递给新进程
+	defer engine.removeListener(origin)
+	if conf.ProxyProtocol != nil {
+		pl, err := NewProxyListener(l, conf.ProxyProtocol)
+		if err != nil {
+			l.Close()
+			return err
+		}
+		l = pl
+	}
 	serve := &http.Server{
-		Addr:         address,
+		Addr:         l.Addr().String(),
 		Handler:      engine.Router,
 		ReadTimeout:  conf.ReadTimeout,
 		WriteTimeout: conf.WriteTimeout,
+		TLSConfig:    tlsConf,
 	}
-	engine.server.Store(serve)
-	log.Print("http server run at:" + address + "...")
-	if err := serve.ListenAndServe(); err != nil {
-		panic(err)
+	if !engine.addServer(serve) {
+		// Shutdown 先于 addServer 执行时不再启动
+		l.Close()
+		return nil
+	}
+	defer engine.removeServer(serve)
+	notifyReady(origin)
+	log.Print("http server run at:" + serve.Addr + "...")
+	if tlsConf != nil {
+		err = serve.ServeTLS(l, "", "")
+	} else {
+		err = serve.Serve(l)
+	}
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// addServer 记录运行的 http server，engine 已经开始关闭时返回 false
+func (engine *Engine) addServer(server *http.Server) bool {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	select {
+	case <-engine.closing:
+		return false
+	default:
+	}
+	if engine.servers == nil {
+		engine.servers = make(map[*http.Server]struct{})
+	}
+	engine.servers[server] = struct{}{}
+	engine.server.Store(server)
+	return true
+}
+
+func (engine *Engine) removeServer(server *http.Server) {
+	engine.mu.Lock()
+	delete(engine.servers, server)
+	engine.mu.Unlock()
+}
+
+// runningServers 返回正在运行的 http server
+func (engine *Engine) runningServers() []*http.Server {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	servers := make([]*http.Server, 0, len(engine.servers))
+	for server := range engine.servers {
+		servers = append(servers, server)
 	}
+	return servers
 }
 
-// Server 返回 engine 的 http server
+// Server 返回 engine 最后启动的 http server
+// 调用多次 Run、RunTLS 或 RunListener 时有多个 http server，Shutdown 会关闭所有的 http server
 func (engine *Engine) Server() *http.Server {
 	if server, ok := engine.server.Load().(*http.Server); ok {
 		return server
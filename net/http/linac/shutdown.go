@@ -0,0 +1,141 @@
+package linac
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// _defaultShutdownTimeout 未配置 ShutdownTimeout 时等待处理中请求的最长时间
+const _defaultShutdownTimeout = 30 * time.Second
+
+// OnShutdown 注册关闭 engine 时执行的 hook，如关闭数据库连接、刷新日志等
+// hook 在处理中的请求结束 (或等待超时) 后执行，后注册的先执行
+func (engine *Engine) OnShutdown(hook func(ctx context.Context) error) {
+	engine.mu.Lock()
+	engine.hooks = append(engine.hooks, hook)
+	engine.mu.Unlock()
+}
+
+// Closing 返回 engine 开始关闭时被关闭的 channel
+func (engine *Engine) Closing() <-chan struct{} {
+	return engine.closing
+}
+
+// Closing 返回 engine 开始关闭时被关闭的 channel
+// SSE、WebSocket 等长连接的 handler 应在收到通知后通知客户端并尽快返回，否则会一直等待到关闭超时
+func (ctx *Context) Closing() <-chan struct{} {
+	if ctx.closing == nil {
+		// 未通过 engine 处理的请求永远不会收到关闭通知
+		return make(chan struct{})
+	}
+	return ctx.closing
+}
+
+// Shutdown 优雅关闭 engine
+// 关闭 Run、RunTLS 与 RunListener 启动的所有 http server，停止接受新连接，通知长连接关闭，等待处理中的请求 (包括被 Hijack 的连接) 结束后执行 OnShutdown 注册的 hook。
+// ctx 结束时不再等待，仍然执行 hook，并返回 ctx 的错误
+func (engine *Engine) Shutdown(ctx context.Context) (err error) {
+	engine.closeOnce.Do(func() {
+		close(engine.closing)
+	})
+	servers := engine.runningServers()
+	errs := make(chan error, len(servers))
+	for _, server := range servers {
+		go func(server *http.Server) {
+			errs <- server.Shutdown(ctx)
+		}(server)
+	}
+	for range servers {
+		if serverErr := <-errs; err == nil {
+			err = serverErr
+		}
+	}
+	if err == nil {
+		err = engine.wait(ctx)
+	}
+	if hookErr := engine.runHooks(ctx); err == nil {
+		err = hookErr
+	}
+	return
+}
+
+// RunWithSignals 运行 http server engine，收到 SIGINT 或 SIGTERM 后优雅关闭
+// 收到 SIGUSR2 时调用 Restart 启动新的进程，新进程就绪后优雅关闭，新进程启动失败时继续服务。
+// 最多等待 ServerConfig.ShutdownTimeout，正常关闭时返回 nil
+func (engine *Engine) RunWithSignals(address string) error {
+	sig := make(chan os.Signal, 1)
+	signals := []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	if _restartSignal != nil {
+		signals = append(signals, _restartSignal)
+	}
+	signal.Notify(sig, signals...)
+	defer signal.Stop(sig)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- engine.Run(address)
+	}()
+	for closing := false; !closing; {
+		select {
+		case err := <-errc:
+			return err
+		case s := <-sig:
+			if s != _restartSignal {
+				log.Printf("http server receive signal %s, shutting down...", s)
+				closing = true
+				break
+			}
+			pid, err := engine.Restart()
+			if err != nil {
+				log.Printf("http server restart error: %v", err)
+				break
+			}
+			log.Printf("http server restarted with pid %d, shutting down...", pid)
+			closing = true
+		}
+	}
+	timeout := engine.GetConfig().ShutdownTimeout
+	if timeout <= 0 {
+		timeout = _defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return engine.Shutdown(ctx)
+}
+
+// wait 等待处理中的请求结束
+// http.Server.Shutdown 不会等待被 Hijack 的连接，handler 返回前仍然计入 engine.active
+func (engine *Engine) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		engine.active.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runHooks 逆序执行 OnShutdown 注册的 hook，返回第一个错误
+func (engine *Engine) runHooks(ctx context.Context) (err error) {
+	engine.mu.Lock()
+	hooks := engine.hooks
+	engine.hooks = nil
+	engine.mu.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if hookErr := hooks[i](ctx); hookErr != nil {
+			log.Printf("http server shutdown hook error: %v", hookErr)
+			if err == nil {
+				err = hookErr
+			}
+		}
+	}
+	return
+}
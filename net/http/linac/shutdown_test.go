@@ -0,0 +1,172 @@
+package linac
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func serveTest(t *testing.T, engine *Engine) (addr string, errc chan error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	errc = make(chan error, 1)
+	go func() {
+		errc <- engine.serve(l)
+	}()
+	return l.Addr().String(), errc
+}
+
+func TestShutdown(t *testing.T) {
+	t.Run("drain in flight", func(t *testing.T) {
+		engine := NewEngine()
+		started := make(chan struct{})
+		engine.GET("/shutdown-slow", "shutdown.slow", func(ctx *Context) {
+			close(started)
+			time.Sleep(100 * time.Millisecond)
+			ctx.String(200, "done")
+		})
+		var hooks []string
+		engine.OnShutdown(func(ctx context.Context) error {
+			hooks = append(hooks, "first")
+			return nil
+		})
+		engine.OnShutdown(func(ctx context.Context) error {
+			hooks = append(hooks, "second")
+			return nil
+		})
+		addr, errc := serveTest(t, engine)
+
+		respc := make(chan string, 1)
+		go func() {
+			resp, err := http.Get(uri(addr, "/shutdown-slow"))
+			if err != nil {
+				respc <- err.Error()
+				return
+			}
+			defer resp.Body.Close()
+			body, _ := ioutil.ReadAll(resp.Body)
+			respc <- string(body)
+		}()
+		<-started
+		assert.Nil(t, engine.Shutdown(context.Background()))
+		assert.Equal(t, "done", <-respc)
+		assert.Nil(t, <-errc)
+		assert.Equal(t, []string{"second", "first"}, hooks)
+
+		_, err := http.Get(uri(addr, "/shutdown-slow"))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("notify long lived", func(t *testing.T) {
+		engine := NewEngine()
+		started := make(chan struct{})
+		engine.GET("/shutdown-stream", "shutdown.stream", func(ctx *Context) {
+			close(started)
+			<-ctx.Closing()
+			ctx.String(200, "closing")
+		})
+		addr, errc := serveTest(t, engine)
+		respc := make(chan int, 1)
+		go func() {
+			resp, err := http.Get(uri(addr, "/shutdown-stream"))
+			if err != nil {
+				respc <- 0
+				return
+			}
+			resp.Body.Close()
+			respc <- resp.StatusCode
+		}()
+		<-started
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.Nil(t, engine.Shutdown(ctx))
+		assert.Equal(t, 200, <-respc)
+		assert.Nil(t, <-errc)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		engine := NewEngine()
+		started, release := make(chan struct{}), make(chan struct{})
+		engine.GET("/shutdown-stuck", "shutdown.stuck", func(ctx *Context) {
+			close(started)
+			<-release
+		})
+		hookErr := errors.New("hook")
+		called := false
+		engine.OnShutdown(func(ctx context.Context) error {
+			called = true
+			return hookErr
+		})
+		addr, _ := serveTest(t, engine)
+		go http.Get(uri(addr, "/shutdown-stuck"))
+		<-started
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		assert.Equal(t, context.DeadlineExceeded, engine.Shutdown(ctx))
+		assert.True(t, called)
+		close(release)
+	})
+
+	t.Run("multiple listeners", func(t *testing.T) {
+		engine := NewEngine()
+		engine.GET("/shutdown-multi", "shutdown.multi", func(ctx *Context) {
+			ctx.String(200, "ok")
+		})
+		addr1, errc1 := serveTest(t, engine)
+		addr2, errc2 := serveTest(t, engine)
+		for _, addr := range []string{addr1, addr2} {
+			resp, err := http.Get(uri(addr, "/shutdown-multi"))
+			assert.Nil(t, err)
+			resp.Body.Close()
+		}
+		assert.Len(t, engine.runningServers(), 2)
+		assert.Nil(t, engine.Shutdown(context.Background()))
+		for _, errc := range []chan error{errc1, errc2} {
+			select {
+			case err := <-errc:
+				assert.Nil(t, err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("serve did not return")
+			}
+		}
+		for _, addr := range []string{addr1, addr2} {
+			_, err := net.Dial("tcp", addr)
+			assert.NotNil(t, err)
+		}
+	})
+
+	t.Run("run error", func(t *testing.T) {
+		assert.NotNil(t, NewEngine().Run("invalid address"))
+	})
+}
+
+func TestRunWithSignals(t *testing.T) {
+	engine := NewEngine()
+	closed := make(chan struct{})
+	engine.OnShutdown(func(ctx context.Context) error {
+		close(closed)
+		return nil
+	})
+	errc := make(chan error, 1)
+	go func() {
+		errc <- engine.RunWithSignals("127.0.0.1:0")
+	}()
+	for engine.Server() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Nil(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+	select {
+	case err := <-errc:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunWithSignals did not return")
+	}
+	<-closed
+}
@@ -0,0 +1,176 @@
+// Package sign 使用 HMAC-SHA256 对 http 请求签名
+//
+// 签名内容为 method、path、按 key 与 value 排序的 query、请求体的 SHA256、时间戳、nonce 与 app key，
+// 以换行分隔。签名结果以十六进制写入 X-Signature 请求头
+package sign
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 签名相关的请求头
+const (
+	HeaderAppKey    = "X-App-Key"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderNonce     = "X-Nonce"
+	HeaderSignature = "X-Signature"
+)
+
+// Signer 请求签名
+type Signer struct {
+	// AppKey 调用方的 app key
+	AppKey string
+	// Secret app key 对应的密钥
+	Secret []byte
+
+	now func() time.Time
+}
+
+// New 返回请求签名
+func New(appKey string, secret []byte) *Signer {
+	return &Signer{AppKey: appKey, Secret: secret}
+}
+
+// Sign 为请求设置时间戳、nonce 与签名请求头
+// 请求体会被完整读取并替换为可重复读取的副本
+func (s *Signer) Sign(req *http.Request) error {
+	body, err := readBody(req)
+	if err != nil {
+		return err
+	}
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	ts := strconv.FormatInt(now().Unix(), 10)
+	nonce, err := newNonce()
+	if err != nil {
+		return err
+	}
+	req.Header.Set(HeaderAppKey, s.AppKey)
+	req.Header.Set(HeaderTimestamp, ts)
+	req.Header.Set(HeaderNonce, nonce)
+	req.Header.Set(HeaderSignature, Signature(s.Secret, req.Method, req.URL, BodyHash(body), ts, nonce, s.AppKey))
+	return nil
+}
+
+// Transport 对请求签名的 http.RoundTripper
+type Transport struct {
+	Signer *Signer
+	// Base 实际发送请求的 RoundTripper，为 nil 时使用 http.DefaultTransport
+	Base http.RoundTripper
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	// RoundTripper 不应修改原请求
+	req = req.Clone(req.Context())
+	if err := t.Signer.Sign(req); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return base.RoundTrip(req)
+}
+
+// Signature 计算签名，bodyHash 为 BodyHash 的结果
+func Signature(secret []byte, method string, u *url.URL, bodyHash, timestamp, nonce, appKey string) string {
+	mac := hmac.New(sha256.New, secret)
+	io.WriteString(mac, StringToSign(method, u, bodyHash, timestamp, nonce, appKey))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 常量时间比较签名
+func Verify(secret []byte, signature, method string, u *url.URL, bodyHash, timestamp, nonce, appKey string) bool {
+	expected := Signature(secret, method, u, bodyHash, timestamp, nonce, appKey)
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(signature)))
+}
+
+// StringToSign 返回待签名的字符串
+func StringToSign(method string, u *url.URL, bodyHash, timestamp, nonce, appKey string) string {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		sortedQuery(u.Query()),
+		bodyHash,
+		timestamp,
+		nonce,
+		appKey,
+	}, "\n")
+}
+
+// BodyHash 返回请求体 SHA256 的十六进制
+func BodyHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// sortedQuery 按 key 与 value 排序编码 query
+func sortedQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf strings.Builder
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			if buf.Len() > 0 {
+				buf.WriteByte('&')
+			}
+			buf.WriteString(url.QueryEscape(k))
+			buf.WriteByte('=')
+			buf.WriteString(url.QueryEscape(v))
+		}
+	}
+	return buf.String()
+}
+
+// readBody 读取请求体，并替换为可重复读取的副本
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
+
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.New("sign: generate nonce: " + err.Error())
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,68 @@
+package sign
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringToSign(t *testing.T) {
+	u, _ := url.Parse("http://example.com/api/v1/users?b=2&a=3&b=1&c=x%20y")
+	s := StringToSign("post", u, BodyHash([]byte("{}")), "1700000000", "n1", "app")
+	assert.Equal(t, strings.Join([]string{
+		"POST",
+		"/api/v1/users",
+		"a=3&b=1&b=2&c=x+y",
+		"44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a",
+		"1700000000",
+		"n1",
+		"app",
+	}, "\n"), s)
+
+	u, _ = url.Parse("http://example.com")
+	assert.True(t, strings.HasPrefix(StringToSign("GET", u, "", "", "", ""), "GET\n/\n"))
+}
+
+func TestSigner(t *testing.T) {
+	secret := []byte("secret")
+	s := New("app", secret)
+	s.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	req := httptest.NewRequest(http.MethodPost, "/orders?id=1", strings.NewReader(`{"n":1}`))
+	assert.Nil(t, s.Sign(req))
+	assert.Equal(t, "app", req.Header.Get(HeaderAppKey))
+	assert.Equal(t, "1700000000", req.Header.Get(HeaderTimestamp))
+	assert.Len(t, req.Header.Get(HeaderNonce), 32)
+	assert.True(t, Verify(secret, req.Header.Get(HeaderSignature), req.Method, req.URL,
+		BodyHash([]byte(`{"n":1}`)), req.Header.Get(HeaderTimestamp), req.Header.Get(HeaderNonce), "app"))
+
+	// 签名后请求体仍可读取
+	body, _ := ioutil.ReadAll(req.Body)
+	assert.Equal(t, `{"n":1}`, string(body))
+}
+
+func TestTransport(t *testing.T) {
+	secret := []byte("secret")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		h := r.Header
+		if !Verify(secret, h.Get(HeaderSignature), r.Method, r.URL, BodyHash(body), h.Get(HeaderTimestamp), h.Get(HeaderNonce), h.Get(HeaderAppKey)) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	cli := &http.Client{Transport: &Transport{Signer: New("app", secret)}}
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+"/a?x=1", strings.NewReader("payload"))
+	resp, err := cli.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "", req.Header.Get(HeaderSignature))
+}
@@ -0,0 +1,142 @@
+package linac
+
+import (
+	xerror "linac/error"
+	"linac/net/http/linac/sign"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// _defaultMaxSkew 请求时间戳与服务器时间允许的最大误差
+	_defaultMaxSkew = time.Minute * 5
+)
+
+// NonceStore 记录已经使用过的 nonce，用于防止重放
+type NonceStore interface {
+	// Use 记录 nonce，ttl 内已经使用过时返回 false
+	Use(nonce string, ttl time.Duration) bool
+}
+
+// MemoryNonceStore 内存 nonce 存储，定期清理过期的 nonce
+type MemoryNonceStore struct {
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryNonceStore 返回内存 nonce 存储
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{
+		nonces: make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// Use 记录 nonce，ttl 内已经使用过时返回 false
+func (s *MemoryNonceStore) Use(nonce string, ttl time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.lastSweep) >= _sweepInterval {
+		for n, expire := range s.nonces {
+			if now.After(expire) {
+				delete(s.nonces, n)
+			}
+		}
+		s.lastSweep = now
+	}
+	if expire, ok := s.nonces[nonce]; ok && !now.After(expire) {
+		return false
+	}
+	s.nonces[nonce] = now.Add(ttl)
+	return true
+}
+
+// Len 返回当前保存的 nonce 数量
+func (s *MemoryNonceStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.nonces)
+}
+
+// SignatureConfig 请求签名校验配置
+type SignatureConfig struct {
+	// Secret 返回 app key 对应的密钥，必须设置
+	Secret func(appKey string) ([]byte, bool)
+	// MaxSkew 请求时间戳与服务器时间允许的最大误差，默认 5 分钟
+	MaxSkew time.Duration
+	// Nonces 已使用的 nonce 存储，默认为内存存储
+	Nonces NonceStore
+
+	now func() time.Time
+}
+
+// VerifySignature 请求签名校验中间件，签名方式见 sign 包
+// 签名错误、时间戳超过允许误差或 nonce 重复使用时返回 401，
+// 校验通过后 app key 作为 sub 保存在 ctx.Claims() 中。
+// multipart 请求体在路由解析表单时已被读取，使用解析时计算的请求体摘要校验
+func VerifySignature(conf *SignatureConfig) Handler {
+	if conf.Secret == nil {
+		panic("signature: secret must not be nil")
+	}
+	maxSkew := conf.MaxSkew
+	if maxSkew <= 0 {
+		maxSkew = _defaultMaxSkew
+	}
+	nonces := conf.Nonces
+	if nonces == nil {
+		nonces = NewMemoryNonceStore()
+	}
+	now := conf.now
+	if now == nil {
+		now = time.Now
+	}
+	return func(ctx *Context) {
+		header := ctx.Request.Header
+		appKey := header.Get(sign.HeaderAppKey)
+		ts := header.Get(sign.HeaderTimestamp)
+		nonce := header.Get(sign.HeaderNonce)
+		signature := header.Get(sign.HeaderSignature)
+		if appKey == "" || ts == "" || nonce == "" || signature == "" {
+			ctx.unauthorized("")
+			return
+		}
+		sec, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			ctx.unauthorized("")
+			return
+		}
+		if skew := now().Sub(time.Unix(sec, 0)); skew > maxSkew || skew < -maxSkew {
+			ctx.unauthorized("")
+			return
+		}
+		secret, ok := conf.Secret(appKey)
+		if !ok {
+			ctx.unauthorized("")
+			return
+		}
+		bodyHash, err := ctx.bodyHash()
+		if isBodyTooLarge(err) {
+			ctx.AbortWithError(http.StatusRequestEntityTooLarge, xerror.RequestEntityTooLarge)
+			return
+		} else if err != nil {
+			ctx.unauthorized("")
+			return
+		}
+		req := ctx.Request
+		if !sign.Verify(secret, signature, req.Method, req.URL, bodyHash, ts, nonce, appKey) {
+			ctx.unauthorized("")
+			return
+		}
+		// NOTE: 签名通过后才记录 nonce，避免伪造的请求占用 nonce
+		if !nonces.Use(appKey+":"+nonce, maxSkew*2) {
+			ctx.unauthorized("")
+			return
+		}
+		ctx.setClaims(Claims{"sub": appKey}, nil)
+	}
+}
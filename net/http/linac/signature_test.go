@@ -0,0 +1,135 @@
+package linac
+
+import (
+	"bytes"
+	"linac/net/http/linac/sign"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifySignature(t *testing.T) {
+	secret := []byte("secret")
+	engine := NewEngine()
+	engine.Use(VerifySignature(&SignatureConfig{
+		Secret: func(appKey string) ([]byte, bool) {
+			return secret, appKey == "app"
+		},
+		MaxSkew: time.Minute,
+	}))
+	engine.POST("/orders", "signature.orders", func(ctx *Context) {
+		ctx.String(http.StatusOK, "%s %v", ctx.Claims().Subject(), ctx.Post("name"))
+	})
+	signer := sign.New("app", secret)
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/orders?b=2&a=1", strings.NewReader(url.Values{"name": {"linac"}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		req := newRequest()
+		assert.Nil(t, signer.Sign(req))
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "app linac", w.Body.String())
+
+		// 重放
+		replay := newRequest()
+		replay.Header = req.Header
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, replay)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":401`)
+	})
+
+	t.Run("multipart", func(t *testing.T) {
+		newMultipart := func(content string) *http.Request {
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			mw.WriteField("name", "linac")
+			fw, _ := mw.CreateFormFile("file", "a.txt")
+			fw.Write([]byte(content))
+			mw.Close()
+			req := httptest.NewRequest(http.MethodPost, "/orders", &body)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			return req
+		}
+		req := newMultipart("hello")
+		assert.Nil(t, signer.Sign(req))
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "app linac", w.Body.String())
+
+		signed := newMultipart("hello")
+		assert.Nil(t, signer.Sign(signed))
+		tampered := newMultipart("world")
+		tampered.Header = signed.Header
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, tampered)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		req := newRequest()
+		assert.Nil(t, signer.Sign(req))
+		tampered := httptest.NewRequest(http.MethodPost, "/orders?b=2&a=1", strings.NewReader("name=other"))
+		tampered.Header = req.Header
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, tampered)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		req := newRequest()
+		assert.Nil(t, signer.Sign(req))
+		stale := strconv.FormatInt(time.Now().Add(-time.Minute*2).Unix(), 10)
+		req.Header.Set(sign.HeaderTimestamp, stale)
+		req.Header.Set(sign.HeaderSignature, sign.Signature(secret, req.Method, req.URL,
+			sign.BodyHash([]byte("name=linac")), stale, req.Header.Get(sign.HeaderNonce), "app"))
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("unknown app key", func(t *testing.T) {
+		req := newRequest()
+		assert.Nil(t, sign.New("other", secret).Sign(req))
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("body too large", func(t *testing.T) {
+		conf := *engine.GetConfig()
+		conf.MaxRequestBody = 4
+		engine.SetConfig(&conf)
+		req := newRequest()
+		assert.Nil(t, signer.Sign(req))
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":413`)
+	})
+}
+
+func TestMemoryNonceStore(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	s := NewMemoryNonceStore()
+	s.now = clock.Now
+	assert.True(t, s.Use("n1", time.Minute))
+	assert.False(t, s.Use("n1", time.Minute))
+	clock.now = clock.now.Add(time.Minute * 2)
+	assert.True(t, s.Use("n2", time.Minute))
+	assert.Equal(t, 1, s.Len())
+	assert.True(t, s.Use("n1", time.Minute))
+}
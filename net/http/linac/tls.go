@@ -0,0 +1,204 @@
+package linac
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	xerror "linac/error"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"time"
+)
+
+// _tlsReloadInterval 检查证书文件是否修改的默认间隔
+const _tlsReloadInterval = 10 * time.Second
+
+var errNoTLSConfig = errors.New("linac: tls config must not be nil")
+
+// TLSConfig HTTPS 配置
+type TLSConfig struct {
+	// CertFile 与 KeyFile PEM 格式的证书链与私钥
+	CertFile string
+	KeyFile  string
+	// MinVersion 最低的 TLS 版本，默认 tls.VersionTLS12
+	MinVersion uint16
+	// CipherSuites TLS 1.2 及以下版本允许的加密套件，为空时使用默认值，TLS 1.3 的套件不可配置
+	CipherSuites []uint16
+	// ClientCAFile 不为空时使用其中的 CA 验证客户端证书 (mTLS)
+	ClientCAFile string
+	// ClientAuth 客户端证书的验证方式，设置了 ClientCAFile 且为零值时为 tls.RequireAndVerifyClientCert
+	ClientAuth tls.ClientAuthType
+	// ReloadInterval 检查证书文件修改时间的间隔，默认 10 秒，文件修改后重新加载；
+	// 收到 SIGHUP 时立即重新加载
+	ReloadInterval time.Duration
+}
+
+// RunTLS 以 HTTPS 运行 http server engine，使用 ServerConfig.TLS 中的证书
+// 证书与客户端 CA 修改后无需重启即可生效，新的连接使用新的证书
+func (engine *Engine) RunTLS(address string) error {
+	conf := engine.GetConfig().TLS
+	if conf == nil {
+		return errNoTLSConfig
+	}
+	reloader, err := newCertReloader(conf)
+	if err != nil {
+		return err
+	}
+	l, err := engine.listen(address)
+	if err != nil {
+		return err
+	}
+	go reloader.watch(engine.closing)
+	return engine.serveTLS(l, reloader.tlsConfig())
+}
+
+// ClientCertificate 返回经过验证的客户端证书，未使用 mTLS 或客户端未提供证书时返回 nil
+func (ctx *Context) ClientCertificate() *x509.Certificate {
+	state := ctx.Request.TLS
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	return state.PeerCertificates[0]
+}
+
+// ClientCert 客户端证书认证中间件
+// 没有经过验证的客户端证书时返回 401，Claims 的 sub 为证书的 CommonName，
+// 并包含 serial、dns、email 与 uri 字段；authorize 返回 false 时返回 403
+func ClientCert(authorize func(*Context, Claims) bool) Handler {
+	return func(ctx *Context) {
+		cert := ctx.ClientCertificate()
+		if cert == nil {
+			ctx.AbortWithError(http.StatusUnauthorized, xerror.Unauthorized)
+			return
+		}
+		uris := make([]string, 0, len(cert.URIs))
+		for _, u := range cert.URIs {
+			uris = append(uris, u.String())
+		}
+		ctx.setClaims(Claims{
+			"sub":    cert.Subject.CommonName,
+			"serial": cert.SerialNumber.String(),
+			"dns":    cert.DNSNames,
+			"email":  cert.EmailAddresses,
+			"uri":    uris,
+		}, authorize)
+	}
+}
+
+// certReloader 加载证书与客户端 CA，文件修改或收到 SIGHUP 时重新加载
+// 加载失败时记录日志并继续使用旧的证书
+type certReloader struct {
+	conf    *TLSConfig
+	config  atomic.Value // NOTE: struct *tls.Config
+	modTime time.Time
+}
+
+func newCertReloader(conf *TLSConfig) (*certReloader, error) {
+	r := &certReloader{conf: conf}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load 加载证书与客户端 CA
+func (r *certReloader) load() error {
+	conf := r.conf
+	modTime := r.lastModified()
+	cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+	if err != nil {
+		return err
+	}
+	minVersion := conf.MinVersion
+	if minVersion == 0 {
+		minVersion = tls.VersionTLS12
+	}
+	c := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   minVersion,
+		CipherSuites: conf.CipherSuites,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if conf.ClientCAFile != "" {
+		bs, err := ioutil.ReadFile(conf.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bs) {
+			return errors.New("linac: no certificate found in " + conf.ClientCAFile)
+		}
+		c.ClientCAs = pool
+		c.ClientAuth = conf.ClientAuth
+		if c.ClientAuth == tls.NoClientCert {
+			c.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	r.config.Store(c)
+	r.modTime = modTime
+	return nil
+}
+
+// lastModified 返回证书文件中最新的修改时间
+func (r *certReloader) lastModified() (modTime time.Time) {
+	for _, file := range []string{r.conf.CertFile, r.conf.KeyFile, r.conf.ClientCAFile} {
+		if file == "" {
+			continue
+		}
+		if info, err := os.Stat(file); err == nil && info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	return
+}
+
+// watch 定期检查证书文件并在收到 SIGHUP 时重新加载，closing 关闭时返回
+func (r *certReloader) watch(closing <-chan struct{}) {
+	interval := r.conf.ReloadInterval
+	if interval <= 0 {
+		interval = _tlsReloadInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	sighup := make(chan os.Signal, 1)
+	if _reloadSignal != nil {
+		signal.Notify(sighup, _reloadSignal)
+		defer signal.Stop(sighup)
+	}
+	for {
+		select {
+		case <-ticker.C:
+			if !r.lastModified().After(r.modTime) {
+				continue
+			}
+		case <-sighup:
+		case <-closing:
+			return
+		}
+		if err := r.load(); err != nil {
+			log.Printf("http server reload certificate error: %v", err)
+			continue
+		}
+		log.Printf("http server reload certificate: %s", r.conf.CertFile)
+	}
+}
+
+// tlsConfig 返回每次握手时使用最新证书的 tls.Config
+func (r *certReloader) tlsConfig() *tls.Config {
+	current := func() *tls.Config {
+		return r.config.Load().(*tls.Config)
+	}
+	return &tls.Config{
+		NextProtos: []string{"h2", "http/1.1"},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return &current().Certificates[0], nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return current(), nil
+		},
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package linac
+
+import "os"
+
+// _reloadSignal 没有 SIGHUP 的平台只定期检查证书文件
+var _reloadSignal os.Signal
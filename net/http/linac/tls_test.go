@@ -0,0 +1,202 @@
+package linac
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pair tls.Certificate
+}
+
+// newTestCert 生成由 parent 签发的证书，parent 为 nil 时生成自签名的 CA
+func newTestCert(t *testing.T, parent *testCert, serial int64, cn string, usage x509.ExtKeyUsage) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		DNSNames:     []string{cn},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	assert.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	return &testCert{cert: cert, key: key, pair: tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}}
+}
+
+func (c *testCert) write(t *testing.T, certFile, keyFile string) {
+	assert.Nil(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0600))
+	if keyFile == "" {
+		return
+	}
+	der, err := x509.MarshalECPrivateKey(c.key)
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600))
+}
+
+func TestRunTLS(t *testing.T) {
+	// watch 注册 SIGHUP 之前收到信号会结束测试进程
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+	dir, err := ioutil.TempDir("", "linac-tls")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	certFile, keyFile, caFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"), filepath.Join(dir, "ca.crt")
+
+	ca := newTestCert(t, nil, 1, "linac test ca", x509.ExtKeyUsageAny)
+	ca.write(t, caFile, "")
+	newTestCert(t, ca, 2, "localhost", x509.ExtKeyUsageServerAuth).write(t, certFile, keyFile)
+	client := newTestCert(t, ca, 3, "client-a", x509.ExtKeyUsageClientAuth)
+
+	engine := NewEngine()
+	engine.SetConfig(&ServerConfig{
+		Timeout: time.Second,
+		TLS: &TLSConfig{
+			CertFile:       certFile,
+			KeyFile:        keyFile,
+			ClientCAFile:   caFile,
+			ClientAuth:     tls.VerifyClientCertIfGiven,
+			ReloadInterval: time.Hour,
+		},
+	})
+	engine.GET("/tls-whoami", "tls.whoami", ClientCert(func(ctx *Context, claims Claims) bool {
+		return claims.Subject() == "client-a"
+	}), func(ctx *Context) {
+		ctx.String(200, "%s", ctx.Claims().Subject())
+	})
+
+	reloader, err := newCertReloader(engine.GetConfig().TLS)
+	assert.Nil(t, err)
+	go reloader.watch(engine.closing)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go engine.serveTLS(l, reloader.tlsConfig())
+	defer engine.Shutdown(context.Background())
+	url := "https://" + l.Addr().String() + "/tls-whoami"
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	newClient := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: pool, Certificates: certs},
+			DisableKeepAlives: true,
+		}}
+	}
+
+	t.Run("client certificate", func(t *testing.T) {
+		resp, err := newClient(client.pair).Get(url)
+		assert.Nil(t, err)
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "client-a", string(body))
+		assert.Equal(t, big.NewInt(2), resp.TLS.PeerCertificates[0].SerialNumber)
+	})
+
+	t.Run("without client certificate", func(t *testing.T) {
+		resp, err := newClient().Get(url)
+		assert.Nil(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("forbidden identity", func(t *testing.T) {
+		other := newTestCert(t, ca, 4, "client-b", x509.ExtKeyUsageClientAuth)
+		resp, err := newClient(other.pair).Get(url)
+		assert.Nil(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("reload on sighup", func(t *testing.T) {
+		newTestCert(t, ca, 5, "localhost", x509.ExtKeyUsageServerAuth).write(t, certFile, keyFile)
+		assert.Nil(t, syscall.Kill(syscall.Getpid(), syscall.SIGHUP))
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			resp, err := newClient(client.pair).Get(url)
+			assert.Nil(t, err)
+			resp.Body.Close()
+			if resp.TLS.PeerCertificates[0].SerialNumber.Int64() == 5 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("certificate was not reloaded")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
+
+func TestCertReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "linac-tls")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	ca := newTestCert(t, nil, 1, "linac test ca", x509.ExtKeyUsageAny)
+	newTestCert(t, ca, 2, "localhost", x509.ExtKeyUsageServerAuth).write(t, certFile, keyFile)
+
+	_, err = newCertReloader(&TLSConfig{CertFile: certFile, KeyFile: filepath.Join(dir, "missing.key")})
+	assert.NotNil(t, err)
+
+	reloader, err := newCertReloader(&TLSConfig{CertFile: certFile, KeyFile: keyFile, ReloadInterval: 10 * time.Millisecond})
+	assert.Nil(t, err)
+	conf, err := reloader.tlsConfig().GetConfigForClient(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), conf.MinVersion)
+	assert.Equal(t, tls.NoClientCert, conf.ClientAuth)
+
+	closing := make(chan struct{})
+	defer close(closing)
+	go reloader.watch(closing)
+	newTestCert(t, ca, 3, "localhost", x509.ExtKeyUsageServerAuth).write(t, certFile, keyFile)
+	future := time.Now().Add(time.Minute)
+	assert.Nil(t, os.Chtimes(certFile, future, future))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		cert, err := reloader.tlsConfig().GetCertificate(nil)
+		assert.Nil(t, err)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		assert.Nil(t, err)
+		if leaf.SerialNumber.Int64() == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("certificate was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package linac
+
+import (
+	"os"
+	"syscall"
+)
+
+// _reloadSignal 收到后重新加载证书的信号
+var _reloadSignal os.Signal = syscall.SIGHUP
@@ -0,0 +1,181 @@
+package linac
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"linac/log"
+	"net/http"
+	"strings"
+)
+
+const (
+	_headerRequestID   = "X-Request-ID"
+	_headerTraceparent = "traceparent"
+	_headerTracestate  = "tracestate"
+
+	// _maxRequestIDLen 允许透传的请求 ID 最大长度，超过时重新生成
+	_maxRequestIDLen = 128
+)
+
+type traceKey struct{}
+
+// TraceContext W3C trace context
+type TraceContext struct {
+	// TraceID 16 字节的 trace id，32 位小写十六进制
+	TraceID string
+	// SpanID 当前服务的 span id，16 位小写十六进制
+	SpanID string
+	// ParentID 上游服务的 span id，新建的 trace 为空
+	ParentID string
+	// Flags trace flags，如 01 表示 sampled
+	Flags byte
+	// State tracestate，原样透传
+	State string
+
+	requestID string
+}
+
+// Traceparent 返回当前 span 的 traceparent
+func (tc *TraceContext) Traceparent() string {
+	return "00-" + tc.TraceID + "-" + tc.SpanID + "-" + hex.EncodeToString([]byte{tc.Flags})
+}
+
+// Trace 请求 ID 与 W3C trace context 中间件
+// 读取或生成 X-Request-ID，解析或新建 traceparent/tracestate，并在响应中返回。
+// 请求 ID 与 trace id 会写入 ctx 的日志字段中，通过 log.Infoc(ctx, ...) 等输出的日志都会包含它们
+func Trace() Handler {
+	return func(ctx *Context) {
+		req := ctx.Request
+		requestID := req.Header.Get(_headerRequestID)
+		if !validRequestID(requestID) {
+			requestID = randomHex(16)
+		}
+		tc, ok := parseTraceparent(req.Header.Get(_headerTraceparent))
+		if ok {
+			tc.State = req.Header.Get(_headerTracestate)
+		} else {
+			tc = &TraceContext{TraceID: randomHex(16), Flags: 0x01}
+		}
+		tc.SpanID = randomHex(8)
+		tc.requestID = requestID
+
+		header := ctx.Writer.Header()
+		header.Set(_headerRequestID, requestID)
+		header.Set(_headerTraceparent, tc.Traceparent())
+		if tc.State != "" {
+			header.Set(_headerTracestate, tc.State)
+		}
+		c := context.WithValue(ctx.Context, traceKey{}, tc)
+		ctx.Context = log.NewContext(c, log.KV("request_id", requestID), log.KV("trace_id", tc.TraceID))
+	}
+}
+
+// RequestID 返回请求 ID，未使用 Trace 中间件时返回请求头中的 X-Request-ID
+func (ctx *Context) RequestID() string {
+	if tc, ok := TraceFromContext(ctx); ok {
+		return tc.requestID
+	}
+	return ctx.Request.Header.Get(_headerRequestID)
+}
+
+// TraceContext 返回请求的 trace context，未使用 Trace 中间件时返回 nil
+func (ctx *Context) TraceContext() *TraceContext {
+	tc, _ := TraceFromContext(ctx)
+	return tc
+}
+
+// TraceFromContext 从 context 中获取 trace context
+func TraceFromContext(c context.Context) (tc *TraceContext, ok bool) {
+	if c == nil {
+		return nil, false
+	}
+	tc, ok = c.Value(traceKey{}).(*TraceContext)
+	return
+}
+
+// InjectTrace 将 c 中的请求 ID 与 trace context 写入请求头，用于向下游服务发起请求
+// 下游请求的 parent id 为当前服务的 span id
+func InjectTrace(c context.Context, req *http.Request) {
+	tc, ok := TraceFromContext(c)
+	if !ok {
+		return
+	}
+	req.Header.Set(_headerRequestID, tc.requestID)
+	req.Header.Set(_headerTraceparent, tc.Traceparent())
+	if tc.State != "" {
+		req.Header.Set(_headerTracestate, tc.State)
+	}
+}
+
+// TraceTransport 向请求中注入请求 ID 与 trace context 的 http.RoundTripper
+// 请求需要通过 http.NewRequestWithContext 携带 *Context 或其派生的 context
+type TraceTransport struct {
+	// Base 实际发送请求的 RoundTripper，为 nil 时使用 http.DefaultTransport
+	Base http.RoundTripper
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *TraceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if _, ok := TraceFromContext(req.Context()); ok {
+		// RoundTripper 不应修改原请求
+		req = req.Clone(req.Context())
+		InjectTrace(req.Context(), req)
+	}
+	return base.RoundTrip(req)
+}
+
+// parseTraceparent 解析 traceparent，格式为 version-traceid-parentid-flags
+func parseTraceparent(s string) (*TraceContext, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) < 55 || !isLowerHex(s[:2]) || s[:2] == "ff" {
+		return nil, false
+	}
+	// 00 版本长度固定，更高的版本允许在后面追加字段
+	if s[:2] == "00" && len(s) != 55 || len(s) > 55 && s[55] != '-' {
+		return nil, false
+	}
+	if s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return nil, false
+	}
+	traceID, parentID, flags := s[3:35], s[36:52], s[53:55]
+	if !isLowerHex(traceID) || !isLowerHex(parentID) || !isLowerHex(flags) ||
+		strings.Count(traceID, "0") == len(traceID) || strings.Count(parentID, "0") == len(parentID) {
+		return nil, false
+	}
+	bs, _ := hex.DecodeString(flags)
+	return &TraceContext{TraceID: traceID, ParentID: parentID, Flags: bs[0]}, true
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// validRequestID 请求 ID 只允许可见的 ASCII 字符，避免日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > _maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(n int) string {
+	bs := make([]byte, n)
+	rand.Read(bs)
+	return hex.EncodeToString(bs)
+}
@@ -0,0 +1,89 @@
+package linac
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tc, ok := parseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.True(t, ok)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", tc.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", tc.ParentID)
+	assert.Equal(t, byte(1), tc.Flags)
+
+	_, ok = parseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-future")
+	assert.True(t, ok)
+
+	for _, s := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+	} {
+		_, ok = parseTraceparent(s)
+		assert.False(t, ok, s)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	var outbound *http.Request
+	transport := &TraceTransport{Base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		outbound = req
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	})}
+	engine := NewEngine()
+	engine.Use(Trace())
+	engine.GET("/trace", "trace", func(ctx *Context) {
+		req, _ := http.NewRequestWithContext(ctx, "GET", "http://downstream/", nil)
+		transport.RoundTrip(req)
+		ctx.String(200, ctx.RequestID())
+	})
+
+	t.Run("Should propagate incoming ids", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/trace", nil)
+		req.Header.Set("X-Request-ID", "req-1")
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		req.Header.Set("tracestate", "vendor=1")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+
+		assert.Equal(t, "req-1", w.Body.String())
+		assert.Equal(t, "req-1", w.Header().Get("X-Request-ID"))
+		assert.Equal(t, "vendor=1", w.Header().Get("tracestate"))
+		tc, ok := parseTraceparent(w.Header().Get("traceparent"))
+		assert.True(t, ok)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", tc.TraceID)
+		assert.NotEqual(t, "00f067aa0ba902b7", tc.ParentID)
+
+		assert.Equal(t, "req-1", outbound.Header.Get("X-Request-ID"))
+		assert.Equal(t, w.Header().Get("traceparent"), outbound.Header.Get("traceparent"))
+		assert.Equal(t, "vendor=1", outbound.Header.Get("tracestate"))
+	})
+
+	t.Run("Should generate ids", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/trace", nil)
+		req.Header.Set("X-Request-ID", "bad id")
+		req.Header.Set("traceparent", "invalid")
+		req.Header.Set("tracestate", "vendor=1")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+
+		assert.Len(t, w.Body.String(), 32)
+		assert.Equal(t, w.Body.String(), w.Header().Get("X-Request-ID"))
+		_, ok := parseTraceparent(w.Header().Get("traceparent"))
+		assert.True(t, ok)
+		assert.Equal(t, "", w.Header().Get("tracestate"))
+	})
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,192 @@
+package linac
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// bufferWriter 缓存整个响应，由中间件在 handler 执行完成后决定输出的内容
+// 调用 Flush 或 Hijack 后不再缓存，直接写入下层 writer
+type bufferWriter struct {
+	http.ResponseWriter
+
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+	streamed    bool
+}
+
+func newBufferWriter(w http.ResponseWriter) *bufferWriter {
+	return &bufferWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (w *bufferWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = code
+	if w.streamed {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *bufferWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	if w.streamed {
+		return w.ResponseWriter.Write(p)
+	}
+	return w.body.Write(p)
+}
+
+// Flush 放弃缓存，输出已缓存的数据并转为流式响应
+func (w *bufferWriter) Flush() {
+	if !w.streamed {
+		w.streamed = true
+		w.flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack 在尚未写入响应时允许接管连接
+func (w *bufferWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if w.wroteHeader {
+		return nil, nil, errors.New("linac: hijack after response written")
+	}
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("linac: response writer does not implement http.Hijacker")
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.streamed = true
+	}
+	return conn, rw, err
+}
+
+// flush 将缓存的状态码和响应体写入下层 writer
+func (w *bufferWriter) flush() {
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.body.Len() > 0 {
+		w.ResponseWriter.Write(w.body.Bytes())
+		w.body.Reset()
+	}
+}
+
+// statusWriter 记录响应的状态码和响应体大小
+type statusWriter struct {
+	http.ResponseWriter
+
+	status      int
+	size        int64
+	wroteHeader bool
+}
+
+func newStatusWriter(w http.ResponseWriter) *statusWriter {
+	return &statusWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("linac: response writer does not implement http.Hijacker")
+	}
+	return h.Hijack()
+}
+
+// recordWriter 在写入下层 writer 的同时记录响应体，用于保存或共享响应
+// 响应体超过 limit 或连接被接管时停止记录，limit <= 0 时不限制
+type recordWriter struct {
+	*statusWriter
+
+	// origin 创建时已有的响应头，由外层中间件设置 (如 X-Request-ID)，不属于记录的响应
+	origin    http.Header
+	body      bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func newRecordWriter(w http.ResponseWriter, limit int) *recordWriter {
+	return &recordWriter{statusWriter: newStatusWriter(w), origin: w.Header().Clone(), limit: limit}
+}
+
+func (w *recordWriter) Write(p []byte) (int, error) {
+	n, err := w.statusWriter.Write(p)
+	if !w.truncated {
+		if w.limit > 0 && w.body.Len()+n > w.limit {
+			w.truncated = true
+			w.body = bytes.Buffer{}
+		} else {
+			w.body.Write(p[:n])
+		}
+	}
+	return n, err
+}
+
+func (w *recordWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.truncated = true
+	return w.statusWriter.Hijack()
+}
+
+// recorded 返回记录完整的响应，header 只包含创建 recordWriter 之后新增或修改的响应头
+func (w *recordWriter) recorded() (status int, header http.Header, body []byte, ok bool) {
+	if w.truncated {
+		return 0, nil, nil, false
+	}
+	header = make(http.Header)
+	for k, v := range w.Header() {
+		if !equalValues(w.origin[k], v) {
+			header[k] = append([]string(nil), v...)
+		}
+	}
+	return w.status, header, w.body.Bytes(), true
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// writeRecorded 输出保存的响应并停止执行后续的 handler
+func (ctx *Context) writeRecorded(status int, header http.Header, body []byte) {
+	h := ctx.Writer.Header()
+	for k, v := range header {
+		h[k] = append([]string(nil), v...)
+	}
+	ctx.Writer.WriteHeader(status)
+	ctx.Writer.Write(body)
+	ctx.abort = true
+}
@@ -0,0 +1,167 @@
+package metric
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+var (
+	// DefaultRegistry 默认的指标注册表
+	DefaultRegistry = NewRegistry()
+
+	// DefaultBuckets 默认的直方图桶，单位为秒
+	DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+)
+
+// VectorOpts 指标选项
+// 指标的完整名称为 Namespace_Subsystem_Name
+type VectorOpts struct {
+	Namespace string
+	Subsystem string
+	Name      string
+	Help      string
+	Labels    []string
+	// Buckets 直方图的桶，为空时使用 DefaultBuckets，只对 Histogram 有效
+	Buckets []float64
+}
+
+func (opts *VectorOpts) fullName() string {
+	var parts []string
+	for _, p := range []string{opts.Namespace, opts.Subsystem, opts.Name} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+// Collector 可以输出为 Prometheus 指标族的指标
+type Collector interface {
+	// Collect 返回当前的指标族
+	Collect() *dto.MetricFamily
+}
+
+// Registry 指标注册表
+type Registry struct {
+	mu         sync.RWMutex
+	names      map[string]struct{}
+	collectors []Collector
+}
+
+// NewRegistry 返回一个新的注册表
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]struct{})}
+}
+
+// MustRegister 注册指标，名称重复时 panic
+func (r *Registry) MustRegister(name string, c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.names[name]; ok {
+		panic(fmt.Errorf("metric: '%s' already registered", name))
+	}
+	r.names[name] = struct{}{}
+	r.collectors = append(r.collectors, c)
+}
+
+// WriteTo 以 Prometheus 文本格式 (version 0.0.4) 输出所有指标
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.RLock()
+	families := make([]*dto.MetricFamily, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		families = append(families, c.Collect())
+	}
+	r.mu.RUnlock()
+	var n int64
+	for _, mf := range families {
+		// NOTE: 还没有任何序列的指标不输出，expfmt 对空的 MetricFamily 返回错误
+		if len(mf.Metric) == 0 {
+			continue
+		}
+		written, err := expfmt.MetricFamilyToText(w, mf)
+		n += int64(written)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// vec 指标向量的公共部分，按标签值保存各个序列
+type vec struct {
+	name   string
+	help   string
+	typ    dto.MetricType
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]interface{}
+	values map[string][]string
+}
+
+func newVec(opts *VectorOpts, typ dto.MetricType) *vec {
+	v := &vec{
+		name:   opts.fullName(),
+		help:   opts.Help,
+		typ:    typ,
+		labels: opts.Labels,
+		series: make(map[string]interface{}),
+		values: make(map[string][]string),
+	}
+	return v
+}
+
+// with 返回标签值对应的序列，不存在时调用 newFunc 创建，调用方需持有锁
+func (v *vec) with(values []string, newFunc func() interface{}) interface{} {
+	if len(values) != len(v.labels) {
+		panic(fmt.Errorf("metric: '%s' expected %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = newFunc()
+		v.series[key] = s
+		v.values[key] = append([]string(nil), values...)
+	}
+	return s
+}
+
+// get 返回标签值对应的序列，调用方需持有锁
+func (v *vec) get(values []string) (interface{}, bool) {
+	s, ok := v.series[strings.Join(values, "\xff")]
+	return s, ok
+}
+
+// collect 按标签值的顺序遍历所有序列，由 fill 填充各个序列的值，调用方需持有锁
+func (v *vec) collect(fill func(m *dto.Metric, s interface{})) *dto.MetricFamily {
+	mf := &dto.MetricFamily{Name: proto.String(v.name), Type: v.typ.Enum()}
+	if v.help != "" {
+		mf.Help = proto.String(v.help)
+	}
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		m := &dto.Metric{Label: v.labelPairs(v.values[k])}
+		fill(m, v.series[k])
+		mf.Metric = append(mf.Metric, m)
+	}
+	return mf
+}
+
+func (v *vec) labelPairs(values []string) []*dto.LabelPair {
+	pairs := make([]*dto.LabelPair, 0, len(values))
+	for i, name := range v.labels {
+		pairs = append(pairs, &dto.LabelPair{Name: proto.String(name), Value: proto.String(values[i])})
+	}
+	return pairs
+}
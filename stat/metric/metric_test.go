@@ -0,0 +1,89 @@
+package metric
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollect(t *testing.T) {
+	t.Run("Should write counter and gauge", func(t *testing.T) {
+		c := NewCounterVec(&VectorOpts{
+			Namespace: "test",
+			Name:      "requests_total",
+			Help:      "Total requests.\nSecond line",
+			Labels:    []string{"route", "code"},
+		})
+		c.Inc("b", "0")
+		c.Add(2, "a\"\n", "500")
+		assert.Equal(t, float64(2), c.Value("a\"\n", "500"))
+		assert.Equal(t, float64(0), c.Value("none", "0"))
+		var buf bytes.Buffer
+		collect(t, &buf, c)
+		assert.Equal(t, "# HELP test_requests_total Total requests.\\nSecond line\n"+
+			"# TYPE test_requests_total counter\n"+
+			"test_requests_total{route=\"a\\\"\\n\",code=\"500\"} 2\n"+
+			"test_requests_total{route=\"b\",code=\"0\"} 1\n", buf.String())
+
+		g := NewGaugeVec(&VectorOpts{Namespace: "test", Name: "in_flight"})
+		g.Inc()
+		g.Inc()
+		g.Dec()
+		collect(t, &buf, g)
+		assert.Equal(t, "# TYPE test_in_flight gauge\ntest_in_flight 1\n", buf.String())
+	})
+
+	t.Run("Should write histogram and summary", func(t *testing.T) {
+		h := NewHistogramVec(&VectorOpts{
+			Namespace: "test",
+			Name:      "duration_seconds",
+			Labels:    []string{"route"},
+			Buckets:   []float64{1, 0.1},
+		})
+		h.Observe(0.1, "r")
+		h.Observe(0.5, "r")
+		h.Observe(3, "r")
+		var buf bytes.Buffer
+		collect(t, &buf, h)
+		assert.Equal(t, "# TYPE test_duration_seconds histogram\n"+
+			"test_duration_seconds_bucket{route=\"r\",le=\"0.1\"} 1\n"+
+			"test_duration_seconds_bucket{route=\"r\",le=\"1\"} 2\n"+
+			"test_duration_seconds_bucket{route=\"r\",le=\"+Inf\"} 3\n"+
+			"test_duration_seconds_sum{route=\"r\"} 3.6\n"+
+			"test_duration_seconds_count{route=\"r\"} 3\n", buf.String())
+
+		s := NewSummaryVec(&VectorOpts{Namespace: "test", Name: "size_bytes"})
+		s.Observe(10)
+		s.Observe(20)
+		collect(t, &buf, s)
+		assert.Equal(t, "# TYPE test_size_bytes summary\ntest_size_bytes_sum 30\ntest_size_bytes_count 2\n", buf.String())
+	})
+
+	t.Run("Should skip vectors without series", func(t *testing.T) {
+		r := NewRegistry()
+		r.MustRegister("test_empty", NewGaugeVec(&VectorOpts{Namespace: "test", Name: "empty", Labels: []string{"l"}}))
+		g := NewGaugeVec(&VectorOpts{Namespace: "test", Name: "set"})
+		g.Set(1)
+		r.MustRegister("test_set", g)
+		var buf bytes.Buffer
+		_, err := r.WriteTo(&buf)
+		assert.Nil(t, err)
+		assert.Equal(t, "# TYPE test_set gauge\ntest_set 1\n", buf.String())
+	})
+
+	t.Run("Should panic on invalid usage", func(t *testing.T) {
+		assert.Panics(t, func() { NewCounterVec(&VectorOpts{Namespace: "test", Name: "requests_total"}) })
+		c := NewCounterVec(&VectorOpts{Namespace: "test", Name: "invalid_total", Labels: []string{"a"}})
+		assert.Panics(t, func() { c.Inc() })
+		assert.Panics(t, func() { c.Add(-1, "a") })
+	})
+}
+
+// collect 将 c 以文本格式写入 buf
+func collect(t *testing.T, buf *bytes.Buffer, c Collector) {
+	buf.Reset()
+	_, err := expfmt.MetricFamilyToText(buf, c.Collect())
+	assert.Nil(t, err)
+}
@@ -0,0 +1,215 @@
+package metric
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// CounterVec 计数器，只能增加
+type CounterVec struct {
+	*vec
+}
+
+// NewCounterVec 创建计数器并注册到 DefaultRegistry
+func NewCounterVec(opts *VectorOpts) *CounterVec {
+	c := &CounterVec{vec: newVec(opts, dto.MetricType_COUNTER)}
+	DefaultRegistry.MustRegister(c.name, c)
+	return c
+}
+
+// Inc 计数加一
+func (c *CounterVec) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+// Add 计数增加 v，v 不能为负数
+func (c *CounterVec) Add(v float64, labels ...string) {
+	if v < 0 {
+		panic(fmt.Errorf("metric: counter '%s' cannot decrease", c.name))
+	}
+	c.mu.Lock()
+	*c.with(labels, newFloat).(*float64) += v
+	c.mu.Unlock()
+}
+
+// Value 返回当前计数
+func (c *CounterVec) Value(labels ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.get(labels); ok {
+		return *s.(*float64)
+	}
+	return 0
+}
+
+// Collect 实现 Collector
+func (c *CounterVec) Collect() *dto.MetricFamily {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.collect(func(m *dto.Metric, s interface{}) {
+		m.Counter = &dto.Counter{Value: proto.Float64(*s.(*float64))}
+	})
+}
+
+// GaugeVec 可增可减的指标
+type GaugeVec struct {
+	*vec
+}
+
+// NewGaugeVec 创建 gauge 并注册到 DefaultRegistry
+func NewGaugeVec(opts *VectorOpts) *GaugeVec {
+	g := &GaugeVec{vec: newVec(opts, dto.MetricType_GAUGE)}
+	DefaultRegistry.MustRegister(g.name, g)
+	return g
+}
+
+// Set 设置当前值
+func (g *GaugeVec) Set(v float64, labels ...string) {
+	g.mu.Lock()
+	*g.with(labels, newFloat).(*float64) = v
+	g.mu.Unlock()
+}
+
+// Add 当前值增加 v
+func (g *GaugeVec) Add(v float64, labels ...string) {
+	g.mu.Lock()
+	*g.with(labels, newFloat).(*float64) += v
+	g.mu.Unlock()
+}
+
+// Inc 当前值加一
+func (g *GaugeVec) Inc(labels ...string) {
+	g.Add(1, labels...)
+}
+
+// Dec 当前值减一
+func (g *GaugeVec) Dec(labels ...string) {
+	g.Add(-1, labels...)
+}
+
+// Value 返回当前值
+func (g *GaugeVec) Value(labels ...string) float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if s, ok := g.get(labels); ok {
+		return *s.(*float64)
+	}
+	return 0
+}
+
+// Collect 实现 Collector
+func (g *GaugeVec) Collect() *dto.MetricFamily {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.collect(func(m *dto.Metric, s interface{}) {
+		m.Gauge = &dto.Gauge{Value: proto.Float64(*s.(*float64))}
+	})
+}
+
+// HistogramVec 直方图
+type HistogramVec struct {
+	*vec
+	buckets []float64
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogramVec 创建直方图并注册到 DefaultRegistry
+func NewHistogramVec(opts *VectorOpts) *HistogramVec {
+	buckets := opts.Buckets
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &HistogramVec{vec: newVec(opts, dto.MetricType_HISTOGRAM), buckets: buckets}
+	DefaultRegistry.MustRegister(h.name, h)
+	return h
+}
+
+// Observe 记录一个观测值
+func (h *HistogramVec) Observe(v float64, labels ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.with(labels, func() interface{} {
+		return &histogram{counts: make([]uint64, len(h.buckets))}
+	}).(*histogram)
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += v
+	s.count++
+}
+
+// Collect 实现 Collector，桶的计数是累积的
+func (h *HistogramVec) Collect() *dto.MetricFamily {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.collect(func(m *dto.Metric, s interface{}) {
+		hist := s.(*histogram)
+		buckets := make([]*dto.Bucket, 0, len(h.buckets))
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += hist.counts[i]
+			buckets = append(buckets, &dto.Bucket{
+				CumulativeCount: proto.Uint64(cumulative),
+				UpperBound:      proto.Float64(upper),
+			})
+		}
+		m.Histogram = &dto.Histogram{
+			SampleCount: proto.Uint64(hist.count),
+			SampleSum:   proto.Float64(hist.sum),
+			Bucket:      buckets,
+		}
+	})
+}
+
+// SummaryVec 摘要，只输出观测值的总和与数量
+type SummaryVec struct {
+	*vec
+}
+
+type summary struct {
+	sum   float64
+	count uint64
+}
+
+// NewSummaryVec 创建摘要并注册到 DefaultRegistry
+func NewSummaryVec(opts *VectorOpts) *SummaryVec {
+	s := &SummaryVec{vec: newVec(opts, dto.MetricType_SUMMARY)}
+	DefaultRegistry.MustRegister(s.name, s)
+	return s
+}
+
+// Observe 记录一个观测值
+func (s *SummaryVec) Observe(v float64, labels ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sm := s.with(labels, func() interface{} { return &summary{} }).(*summary)
+	sm.sum += v
+	sm.count++
+}
+
+// Collect 实现 Collector
+func (s *SummaryVec) Collect() *dto.MetricFamily {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.collect(func(m *dto.Metric, v interface{}) {
+		sm := v.(*summary)
+		m.Summary = &dto.Summary{
+			SampleCount: proto.Uint64(sm.count),
+			SampleSum:   proto.Float64(sm.sum),
+		}
+	})
+}
+
+func newFloat() interface{} {
+	return new(float64)
+}
@@ -0,0 +1,58 @@
+package cpu
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// _interval 采样间隔
+	_interval = time.Millisecond * time.Duration(500)
+	// _decay 滑动平均的衰减系数
+	_decay = 0.95
+)
+
+var (
+	_usage int64
+	_once  sync.Once
+)
+
+// Usage 返回最近的 CPU 使用率 (千分比，0-1000)，为滑动平均值
+// 运行在 cgroup 中时按 cgroup 的 CPU 配额计算，否则按整机计算。
+// 第一次调用时开始后台采样，不支持的平台总是返回 0
+func Usage() int64 {
+	_once.Do(func() {
+		go sample()
+	})
+	return atomic.LoadInt64(&_usage)
+}
+
+// statReader 返回累计的 CPU 使用时间 used 与可用时间 total，两次读取的差值之比即为使用率
+type statReader func() (used, total uint64, err error)
+
+func sample() {
+	ticker := time.NewTicker(_interval)
+	defer ticker.Stop()
+	read := newStatReader()
+	prevUsed, prevTotal, err := read()
+	if err != nil {
+		return
+	}
+	for range ticker.C {
+		used, total, err := read()
+		if err != nil {
+			continue
+		}
+		if total <= prevTotal || used < prevUsed {
+			continue
+		}
+		cur := int64(1000 * float64(used-prevUsed) / float64(total-prevTotal))
+		if cur > 1000 {
+			cur = 1000
+		}
+		prevUsed, prevTotal = used, total
+		prev := atomic.LoadInt64(&_usage)
+		atomic.StoreInt64(&_usage, int64(float64(prev)*_decay+float64(cur)*(1-_decay)))
+	}
+}
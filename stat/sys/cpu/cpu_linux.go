@@ -0,0 +1,172 @@
+package cpu
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	_procStat = "/proc/stat"
+
+	// cgroup v2
+	_cgroupV2Stat = "/sys/fs/cgroup/cpu.stat"
+	_cgroupV2Max  = "/sys/fs/cgroup/cpu.max"
+	// cgroup v1
+	_cgroupV1Usage  = "/sys/fs/cgroup/cpuacct/cpuacct.usage"
+	_cgroupV1Quota  = "/sys/fs/cgroup/cpu/cpu.cfs_quota_us"
+	_cgroupV1Period = "/sys/fs/cgroup/cpu/cpu.cfs_period_us"
+)
+
+// newStatReader 优先读取 cgroup v2、v1 的 CPU 使用时间，都不可用时读取 /proc/stat
+func newStatReader() statReader {
+	if _, err := readCgroupV2Usage(); err == nil {
+		return cgroupReader(readCgroupV2Usage, readCgroupV2Limit)
+	}
+	if _, err := readCgroupV1Usage(); err == nil {
+		return cgroupReader(readCgroupV1Usage, readCgroupV1Limit)
+	}
+	return readStat
+}
+
+// cgroupReader 以 cgroup 的 CPU 使用时间作为 used，经过的时间乘以 CPU 配额 (核数) 作为 total
+// 没有配额时按可用的 CPU 核数计算
+func cgroupReader(usage func() (uint64, error), limit func() (float64, bool)) statReader {
+	var (
+		last  time.Time
+		total uint64
+	)
+	return func() (uint64, uint64, error) {
+		used, err := usage()
+		if err != nil {
+			return 0, 0, err
+		}
+		now := time.Now()
+		if !last.IsZero() {
+			cpus, ok := limit()
+			if !ok {
+				cpus = float64(runtime.NumCPU())
+			}
+			total += uint64(float64(now.Sub(last)) * cpus)
+		}
+		last = now
+		return used, total, nil
+	}
+}
+
+// readCgroupV2Usage 读取 cpu.stat 中的 usage_usec，返回纳秒
+func readCgroupV2Usage() (uint64, error) {
+	bs, err := ioutil.ReadFile(_cgroupV2Stat)
+	if err != nil {
+		return 0, err
+	}
+	return parseCgroupV2Stat(string(bs))
+}
+
+func parseCgroupV2Stat(s string) (uint64, error) {
+	for _, line := range strings.Split(s, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "usage_usec" {
+			usec, err := strconv.ParseUint(fields[1], 10, 64)
+			return usec * uint64(time.Microsecond), err
+		}
+	}
+	return 0, errors.New("cpu: usage_usec not found in " + _cgroupV2Stat)
+}
+
+// readCgroupV2Limit 读取 cpu.max，没有配额时返回 false
+func readCgroupV2Limit() (float64, bool) {
+	bs, err := ioutil.ReadFile(_cgroupV2Max)
+	if err != nil {
+		return 0, false
+	}
+	return parseCgroupV2Max(string(bs))
+}
+
+// parseCgroupV2Max 解析 "$MAX $PERIOD"，$MAX 为 max 表示没有配额
+func parseCgroupV2Max(s string) (float64, bool) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 || fields[0] == "max" {
+		return 0, false
+	}
+	return quota(fields[0], fields[1])
+}
+
+// readCgroupV1Usage 读取 cpuacct.usage，单位为纳秒
+func readCgroupV1Usage() (uint64, error) {
+	bs, err := ioutil.ReadFile(_cgroupV1Usage)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(bs)), 10, 64)
+}
+
+// readCgroupV1Limit 读取 cfs_quota_us 与 cfs_period_us，quota 为 -1 表示没有配额
+func readCgroupV1Limit() (float64, bool) {
+	q, err := ioutil.ReadFile(_cgroupV1Quota)
+	if err != nil {
+		return 0, false
+	}
+	p, err := ioutil.ReadFile(_cgroupV1Period)
+	if err != nil {
+		return 0, false
+	}
+	return quota(strings.TrimSpace(string(q)), strings.TrimSpace(string(p)))
+}
+
+// quota 返回配额对应的 CPU 核数
+func quota(q, period string) (float64, bool) {
+	qv, err := strconv.ParseInt(q, 10, 64)
+	if err != nil || qv <= 0 {
+		return 0, false
+	}
+	pv, err := strconv.ParseInt(period, 10, 64)
+	if err != nil || pv <= 0 {
+		return 0, false
+	}
+	return float64(qv) / float64(pv), true
+}
+
+// readStat 读取 /proc/stat 中的 CPU 累计时间，iowait 计入空闲时间
+func readStat() (used, total uint64, err error) {
+	f, err := os.Open(_procStat)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return 0, 0, errors.New("cpu: empty " + _procStat)
+	}
+	total, idle, err := parseStat(scanner.Text())
+	return total - idle, total, err
+}
+
+// parseStat 解析 /proc/stat 的第一行
+// cpu user nice system idle iowait irq softirq steal guest guest_nice
+func parseStat(line string) (total, idle uint64, err error) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return 0, 0, errors.New("cpu: invalid stat line " + line)
+	}
+	// guest 时间已经包含在 user 中，不再重复计算
+	if len(fields) > 9 {
+		fields = fields[:9]
+	}
+	for i, f := range fields[1:] {
+		v, err := strconv.ParseUint(f, 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		total += v
+		if i == 3 || i == 4 {
+			idle += v
+		}
+	}
+	return
+}
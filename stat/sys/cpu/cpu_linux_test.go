@@ -0,0 +1,56 @@
+package cpu
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStat(t *testing.T) {
+	total, idle, err := parseStat("cpu  100 10 50 800 40 0 0 0 30 0")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1000), total)
+	assert.Equal(t, uint64(840), idle)
+
+	_, _, err = parseStat("intr 1 2 3")
+	assert.NotNil(t, err)
+
+	_, _, err = readStat()
+	assert.Nil(t, err)
+}
+
+func TestParseCgroup(t *testing.T) {
+	usage, err := parseCgroupV2Stat("usage_usec 1500\nuser_usec 1000\nsystem_usec 500\n")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1500000), usage)
+	_, err = parseCgroupV2Stat("user_usec 1000\n")
+	assert.NotNil(t, err)
+
+	cpus, ok := parseCgroupV2Max("150000 100000\n")
+	assert.True(t, ok)
+	assert.Equal(t, 1.5, cpus)
+	_, ok = parseCgroupV2Max("max 100000\n")
+	assert.False(t, ok)
+	_, ok = quota("-1", "100000")
+	assert.False(t, ok)
+}
+
+func TestCgroupReader(t *testing.T) {
+	var used uint64
+	read := cgroupReader(func() (uint64, error) {
+		return used, nil
+	}, func() (float64, bool) {
+		return 2, true
+	})
+	_, total, err := read()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), total)
+	time.Sleep(time.Millisecond * 10)
+	used = uint64(time.Millisecond * 10)
+	u, total, err := read()
+	assert.Nil(t, err)
+	assert.Equal(t, used, u)
+	// 两个核的配额，可用时间至少为经过时间的两倍
+	assert.True(t, total >= uint64(time.Millisecond*20))
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package cpu
+
+import "errors"
+
+func newStatReader() statReader {
+	return func() (used, total uint64, err error) {
+		return 0, 0, errors.New("cpu: unsupported platform")
+	}
+}